@@ -1,3 +1,3 @@
 #! /bin/bash
 
-go run generate.go > index.html
+go run . > index.html
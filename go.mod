@@ -0,0 +1,3 @@
+module github.com/jcrussell/yyl
+
+go 1.21
@@ -0,0 +1,645 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"math"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"runtime/debug"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jcrussell/yyl/yyl"
+)
+
+// defaultIntro describes the original challenge
+const defaultIntro = `In 2015, four boys decided to embark on an epic challenge: eat all 40 items on
+the Yin Yin menu, in order, in less than a year. Half-way through, one moved
+away. The remaining three carried on and emerged as men, victorious.`
+
+var (
+	f_title       = flag.String("title", "Year of the YYL", "report title")
+	f_intro       = flag.String("intro", defaultIntro, "introduction text for the report")
+	f_menu        = flag.String("menu", "menu.csv", "path to menu file, or a comma-separated list of files or globs to merge")
+	f_ratings     = flag.String("ratings", "ratings", "path to ratings directory, or file with -combined")
+	f_combined    = flag.Bool("combined", false, "ratings are in a single file with an extra leading who column")
+	f_delimiter   = flag.String("delimiter", ",", `field delimiter for the menu and ratings, use "\t" for tabs`)
+	f_comment     = flag.String("comment", "", "ignore lines in the menu and ratings starting with this character")
+	f_noHeader    = flag.Bool("no-header", false, "menu and ratings files do not have a header row")
+	f_scaleLabels = flag.String("scale-labels", "", "path to file mapping rating values to labels, such as 4,great or 4,great,5 for ratings out of 5")
+	f_names       = flag.String("names", "", "path to file mapping ratings file names to display names")
+	f_out         = flag.String("out", "", "write output to file instead of stdout")
+	f_format      = flag.String("format", "html", "output format: html, json, markdown, or csv")
+	f_charts      = flag.String("charts", "css", "comma-separated chart renderer, css or svg, and charts to draw, all if none are listed: "+strings.Join(yyl.ChartNames, ", "))
+	f_fill        = flag.String("fill", "#825", "chart fill color")
+	f_track       = flag.String("track", "#ebebeb", "chart track color")
+	f_text        = flag.String("text", "#fff", "chart text color")
+	f_theme       = flag.String("theme", "light", "initial color scheme: light, dark, or auto")
+	f_collapse    = flag.Bool("collapse", false, "start with the menu items collapsed")
+	f_currency    = flag.String("currency", "$", "currency symbol for prices")
+	f_top         = flag.Int("top", 5, "number of top and bottom items to show")
+	f_template    = flag.String("template", "", "html template to use instead of the embedded one")
+	f_img         = flag.String("img", "img", "path to images directory")
+	f_embedImages = flag.Bool("embed-images", false, "embed images in the report as data URIs")
+	f_serve       = flag.String("serve", "", "serve the report over http on this address instead")
+	f_watch       = flag.Bool("watch", false, "regenerate the output when the menu or ratings change")
+	f_check       = flag.Bool("check", false, "validate the menu and ratings and exit non-zero if there are problems, without rendering")
+	f_checkMenu   = flag.Bool("check-menu", false, "check menu for duplicate and missing item numbers")
+	f_strict      = flag.Bool("strict", false, "treat malformed ratings as fatal")
+	f_allowRepeat = flag.Bool("allow-repeats", false, "treat duplicate ratings as revisits, keeping the latest, rather than warning")
+	f_keep        = flag.String("keep", "first", "which duplicate rating to keep: first or last")
+	f_dateFormat  = flag.String("date-format", "", "layout for dates in ratings files, detected if unset")
+	f_byYear      = flag.Bool("by-year", false, "also compute stats for each calendar year")
+	f_sameDay     = flag.Bool("same-day", false, "include same-day visits when computing shortest gap")
+	f_bucket      = flag.Float64("bucket", 1, "width of the buckets in the rating histogram, e.g. 0.5 for half points")
+	f_logLevel    = flag.String("log-level", "info", "minimum level to log: debug, info, warn, or error")
+	f_logFormat   = flag.String("log-format", "text", "log format: text or json")
+	f_weekStart   = flag.String("week-start", "sunday", "first day of the week in the charts: sunday or monday")
+	f_stamp       = flag.Bool("stamp", false, "stamp the version and generation time into an html comment")
+	f_from        = flag.String("from", "", "only include ratings on or after this date, as YYYY-MM-DD")
+	f_to          = flag.String("to", "", "only include ratings on or before this date, as YYYY-MM-DD")
+	f_undated     = flag.String("undated", "include", "whether to keep undated ratings with -from or -to: include or exclude")
+	f_exclude     = flag.String("exclude", "", "comma-separated list of people to leave out of the report")
+	f_anonymize   = flag.Bool("anonymize", false, `replace reviewer names with "Reviewer A", "Reviewer B", etc.`)
+	f_now         = flag.String("now", "", "generation time to show in the report, in RFC 3339 format, defaults to the current time")
+	f_timeFormat  = flag.String("time-format", "2006-01-02 15:04", "layout for the generation time in the report")
+	f_version     = flag.Bool("version", false, "print the version and exit")
+)
+
+// fatalf logs an error and exits
+func fatalf(format string, args ...interface{}) {
+	slog.Error(fmt.Sprintf(format, args...))
+	os.Exit(1)
+}
+
+// setupLogging configures the default logger from -log-level and -log-format
+func setupLogging() error {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(*f_logLevel)); err != nil {
+		return fmt.Errorf("invalid -log-level %q: must be debug, info, warn, or error", *f_logLevel)
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+
+	switch *f_logFormat {
+	case "text":
+		slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, opts)))
+	case "json":
+		slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, opts)))
+	default:
+		return fmt.Errorf("invalid -log-format %q: must be text or json", *f_logFormat)
+	}
+
+	return nil
+}
+
+func main() {
+	flag.Parse()
+
+	if err := setupLogging(); err != nil {
+		fatalf("%v", err)
+	}
+
+	if *f_version {
+		fmt.Println("yyl", version())
+		return
+	}
+
+	menus, err := yyl.MenuFiles(*f_menu)
+	if err != nil {
+		fatalf("invalid -menu %q: %v", *f_menu, err)
+	}
+	for _, fname := range menus {
+		if fi, err := os.Stat(fname); err != nil {
+			fatalf("invalid menu file %v: %v", fname, err)
+		} else if fi.IsDir() {
+			fatalf("invalid menu file %v: is a directory", fname)
+		}
+	}
+
+	if fi, err := os.Stat(*f_ratings); *f_combined {
+		if err != nil {
+			fatalf("invalid ratings file %v: %v", *f_ratings, err)
+		} else if fi.IsDir() {
+			fatalf("invalid ratings file %v: is a directory", *f_ratings)
+		}
+	} else if err != nil {
+		fatalf("invalid ratings directory %v: %v", *f_ratings, err)
+	} else if !fi.IsDir() {
+		fatalf("invalid ratings directory %v: not a directory", *f_ratings)
+	}
+
+	switch *f_format {
+	case "html", "json", "markdown", "csv":
+	default:
+		fatalf("invalid -format %q: must be html, json, markdown, or csv", *f_format)
+	}
+
+	renderer, chartSet, err := parseCharts(*f_charts)
+	if err != nil {
+		fatalf("invalid -charts %q: %v", *f_charts, err)
+	}
+
+	theme := yyl.Theme{
+		Fill:  *f_fill,
+		Track: *f_track,
+		Text:  *f_text,
+	}
+	if err := theme.Validate(); err != nil {
+		fatalf("invalid theme: %v", err)
+	}
+
+	switch *f_theme {
+	case "light", "dark", "auto":
+	default:
+		fatalf("invalid -theme %q: must be light, dark, or auto", *f_theme)
+	}
+
+	if *f_top < 0 {
+		fatalf("invalid -top %v: must be non-negative", *f_top)
+	}
+
+	if *f_keep != "first" && *f_keep != "last" {
+		fatalf("invalid -keep %q: must be first or last", *f_keep)
+	}
+
+	if *f_bucket <= 0 || math.IsNaN(*f_bucket) || math.IsInf(*f_bucket, 0) {
+		fatalf("invalid -bucket %v: must be a positive number", *f_bucket)
+	}
+
+	if *f_weekStart != "sunday" && *f_weekStart != "monday" {
+		fatalf("invalid -week-start %q: must be sunday or monday", *f_weekStart)
+	}
+
+	opts := yyl.CSVOptions{
+		NoHeader: *f_noHeader,
+	}
+
+	delim := *f_delimiter
+	if delim == `\t` {
+		delim = "\t"
+	}
+	if utf8.RuneCountInString(delim) != 1 {
+		fatalf("invalid -delimiter %q: must be a single character", *f_delimiter)
+	}
+	opts.Comma, _ = utf8.DecodeRuneInString(delim)
+	if opts.Comma == '"' || opts.Comma == '\r' || opts.Comma == '\n' || opts.Comma == utf8.RuneError {
+		fatalf("invalid -delimiter %q", *f_delimiter)
+	}
+
+	if *f_comment != "" {
+		if utf8.RuneCountInString(*f_comment) != 1 {
+			fatalf("invalid -comment %q: must be a single character", *f_comment)
+		}
+		opts.Comment, _ = utf8.DecodeRuneInString(*f_comment)
+		if opts.Comment == opts.Comma || opts.Comment == '"' || opts.Comment == '\r' || opts.Comment == '\n' || opts.Comment == utf8.RuneError {
+			fatalf("invalid -comment %q", *f_comment)
+		}
+	}
+
+	layouts := yyl.DateLayouts
+	if *f_dateFormat != "" {
+		if err := yyl.CheckLayout(*f_dateFormat); err != nil {
+			fatalf("invalid date format %q: %v", *f_dateFormat, err)
+		}
+
+		layouts = []string{*f_dateFormat}
+	}
+
+	if *f_undated != "include" && *f_undated != "exclude" {
+		fatalf("invalid -undated %q: must be include or exclude", *f_undated)
+	}
+
+	cfg := configFromFlags(opts, layouts, theme)
+	cfg.Charts, cfg.ChartSet = renderer, chartSet
+
+	for _, v := range []struct {
+		name  string
+		value string
+		dst   *time.Time
+	}{
+		{"from", *f_from, &cfg.From},
+		{"to", *f_to, &cfg.To},
+	} {
+		if v.value == "" {
+			continue
+		}
+
+		t, err := time.Parse("2006-01-02", v.value)
+		if err != nil {
+			fatalf("invalid -%v %q: must be YYYY-MM-DD", v.name, v.value)
+		}
+		*v.dst = t
+	}
+
+	if !cfg.From.IsZero() && !cfg.To.IsZero() && cfg.To.Before(cfg.From) {
+		fatalf("invalid -to %q: must not be before -from %q", *f_to, *f_from)
+	}
+
+	if *f_now != "" {
+		now, err := time.Parse(time.RFC3339, *f_now)
+		if err != nil {
+			fatalf("invalid -now %q: must be in RFC 3339 format", *f_now)
+		}
+
+		cfg.Now = now
+	}
+
+	if *f_check {
+		check(cfg)
+		return
+	}
+
+	// files are re-read on every request or change so cache the unchanged ones
+	if *f_serve != "" || *f_watch {
+		cfg.Cache = yyl.NewCache()
+	}
+
+	if *f_serve != "" {
+		serve(*f_serve, cfg)
+		return
+	}
+
+	generate := func() error {
+		report, err := yyl.BuildReport(cfg)
+		if err != nil {
+			return err
+		}
+
+		return writeOutput(*f_out, func(w io.Writer) error {
+			return render(w, *f_format, *f_template, report)
+		})
+	}
+
+	if err := generate(); err != nil {
+		fatalf("%v", err)
+	}
+
+	if *f_watch {
+		watch(cfg, func() {
+			if err := generate(); err != nil {
+				slog.Error("unable to regenerate", "err", err)
+				return
+			}
+
+			slog.Info("regenerated", "file", *f_out)
+		})
+	}
+}
+
+// countingHandler counts the warnings and errors passed to the wrapped handler
+type countingHandler struct {
+	slog.Handler
+	n *int64
+}
+
+// Enabled is always true for warnings so that they are counted even if the
+// wrapped handler is set to a higher level
+func (h countingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= slog.LevelWarn || h.Handler.Enabled(ctx, level)
+}
+
+func (h countingHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level >= slog.LevelWarn {
+		atomic.AddInt64(h.n, 1)
+	}
+
+	if !h.Handler.Enabled(ctx, r.Level) {
+		return nil
+	}
+
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h countingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return countingHandler{h.Handler.WithAttrs(attrs), h.n}
+}
+
+func (h countingHandler) WithGroup(name string) slog.Handler {
+	return countingHandler{h.Handler.WithGroup(name), h.n}
+}
+
+// check builds the report, logging any problems with the menu or ratings, and
+// exits non-zero if there were any
+func check(cfg yyl.Config) {
+	cfg.CheckMenu = true
+
+	var problems int64
+	slog.SetDefault(slog.New(countingHandler{slog.Default().Handler(), &problems}))
+
+	report, err := yyl.BuildReport(cfg)
+	if err != nil {
+		fatalf("%v", err)
+	}
+
+	for _, who := range report.People() {
+		if n := report.Stats[who].OutOfOrder; n > 0 {
+			slog.Warn("ratings out of order", "who", who, "count", n)
+		}
+	}
+
+	if problems > 0 {
+		fatalf("found %v problems", problems)
+	}
+}
+
+// configFromFlags returns the config set by the command line flags
+func configFromFlags(opts yyl.CSVOptions, layouts []string, theme yyl.Theme) yyl.Config {
+	return yyl.Config{
+		Title:        *f_title,
+		Intro:        *f_intro,
+		Menu:         *f_menu,
+		Ratings:      *f_ratings,
+		Names:        *f_names,
+		ScaleLabels:  *f_scaleLabels,
+		Img:          *f_img,
+		Opts:         opts,
+		Layouts:      layouts,
+		Combined:     *f_combined,
+		EmbedImages:  *f_embedImages,
+		CheckMenu:    *f_checkMenu,
+		Strict:       *f_strict,
+		KeepLast:     *f_keep == "last",
+		AllowRepeats: *f_allowRepeat,
+		ByYear:       *f_byYear,
+		SameDay:      *f_sameDay,
+		Bucket:       float32(*f_bucket),
+		WeekStart:    weekStart(),
+		Top:          *f_top,
+		Theme:        theme,
+		Mode:         *f_theme,
+		Collapse:     *f_collapse,
+		Currency:     *f_currency,
+		TimeFormat:   *f_timeFormat,
+		KeepUndated:  *f_undated == "include",
+		Exclude:      exclude(),
+		Anonymize:    *f_anonymize,
+		Stamp:        *f_stamp,
+		Version:      version(),
+	}
+}
+
+// version returns the module version and vcs revision from the build info
+func version() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+
+	res := info.Main.Version
+	if res == "" {
+		res = "(devel)"
+	}
+
+	var revision, modified string
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			if setting.Value == "true" {
+				modified = "+dirty"
+			}
+		}
+	}
+
+	// pseudo-versions already include the short revision
+	if len(revision) >= 12 && !strings.Contains(res, revision[:12]) {
+		res += " " + revision + modified
+	}
+
+	return res
+}
+
+// parseCharts splits the value of -charts into the renderer, defaulting to css,
+// and the names of the charts to draw
+func parseCharts(s string) (string, []string, error) {
+	renderer := ""
+	var names []string
+
+outer:
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+
+		switch v {
+		case "":
+			continue
+		case "css", "svg":
+			if renderer != "" && renderer != v {
+				return "", nil, fmt.Errorf("must only have one of css or svg")
+			}
+			renderer = v
+			continue
+		}
+
+		for _, name := range yyl.ChartNames {
+			if v == name {
+				names = append(names, v)
+				continue outer
+			}
+		}
+
+		return "", nil, fmt.Errorf("unknown chart %q, must be css, svg, or one of %v", v, strings.Join(yyl.ChartNames, ", "))
+	}
+
+	if renderer == "" {
+		renderer = "css"
+	}
+
+	return renderer, names, nil
+}
+
+// exclude returns the people to leave out, set with -exclude
+func exclude() []string {
+	var res []string
+	for _, v := range strings.Split(*f_exclude, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			res = append(res, v)
+		}
+	}
+
+	return res
+}
+
+// weekStart is the first day of the week in the charts, set with -week-start
+func weekStart() time.Weekday {
+	if *f_weekStart == "monday" {
+		return time.Monday
+	}
+
+	return time.Sunday
+}
+
+// snapshot returns the modification times of the menu and the ratings files
+func snapshot(cfg yyl.Config) map[string]time.Time {
+	res := map[string]time.Time{}
+
+	// globs are expanded again so that new menu files are noticed
+	menus, _ := yyl.MenuFiles(cfg.Menu)
+	for _, fname := range menus {
+		if fi, err := os.Stat(fname); err == nil {
+			res[fname] = fi.ModTime()
+		}
+	}
+
+	if cfg.Combined {
+		if fi, err := os.Stat(cfg.Ratings); err == nil {
+			res[cfg.Ratings] = fi.ModTime()
+		}
+
+		return res
+	}
+
+	files, err := yyl.RatingsFiles(cfg.Ratings)
+	if err != nil {
+		return res
+	}
+
+	for _, fi := range files {
+		res[filepath.Join(cfg.Ratings, fi.Name())] = fi.ModTime()
+	}
+
+	return res
+}
+
+// watch polls the menu and ratings for changes, calling fn once they have
+// stopped changing for watchDebounce. Never returns.
+func watch(cfg yyl.Config, fn func()) {
+	const (
+		watchInterval = 500 * time.Millisecond
+		watchDebounce = time.Second
+	)
+
+	prev := snapshot(cfg)
+	var changed time.Time
+
+	for range time.Tick(watchInterval) {
+		curr := snapshot(cfg)
+
+		if !reflect.DeepEqual(prev, curr) {
+			prev = curr
+			changed = time.Now()
+			continue
+		}
+
+		if !changed.IsZero() && time.Since(changed) >= watchDebounce {
+			changed = time.Time{}
+			fn()
+		}
+	}
+}
+
+// writeOutput runs fn against the output file or stdout if fname is empty. To
+// avoid leaving a truncated file around, output is written to a temporary file
+// in the same directory which is renamed once fn succeeds.
+func writeOutput(fname string, fn func(io.Writer) error) error {
+	if fname == "" {
+		return fn(os.Stdout)
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(fname), "."+filepath.Base(fname))
+	if err != nil {
+		return err
+	}
+
+	// TempFile creates files that are only readable by the owner
+	if err := f.Chmod(0644); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := fn(f); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := os.Rename(f.Name(), fname); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return nil
+}
+
+// imagePrefix returns the path that images in dir are served under. Image srcs
+// are relative to the page at / so dir is resolved the same way the browser
+// resolves them, including any leading "..".
+func imagePrefix(dir string) (string, error) {
+	res := path.Join("/", filepath.ToSlash(filepath.Clean(dir)))
+	if res == "/" {
+		return "", fmt.Errorf("must not be the root when serving")
+	}
+
+	return res + "/", nil
+}
+
+// serve the report over http at addr, rebuilding it for every request so that
+// changes to the data show up on refresh. Images are served from cfg.Img.
+func serve(addr string, cfg yyl.Config) {
+	mux := http.NewServeMux()
+
+	prefix, err := imagePrefix(cfg.Img)
+	if err != nil {
+		fatalf("invalid -img %q: %v", cfg.Img, err)
+	}
+	mux.Handle(prefix, http.StripPrefix(prefix, http.FileServer(http.Dir(cfg.Img))))
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+
+		report, err := yyl.BuildReport(cfg)
+		if err != nil {
+			slog.Error("unable to build report", "err", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// render to a buffer so that errors don't produce a partial page
+		buf := &bytes.Buffer{}
+		if err := render(buf, *f_format, *f_template, report); err != nil {
+			slog.Error("unable to render report", "err", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		switch *f_format {
+		case "json":
+			w.Header().Set("Content-Type", "application/json")
+		case "markdown", "csv":
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		default:
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		}
+
+		buf.WriteTo(w)
+	})
+
+	server := &http.Server{
+		Addr:    addr,
+		Handler: mux,
+	}
+
+	slog.Info("serving report", "addr", addr)
+	fatalf("%v", server.ListenAndServe())
+}
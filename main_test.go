@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestImagePrefix(t *testing.T) {
+	base, _ := url.Parse("http://localhost:8080/")
+
+	for _, dir := range []string{"img", "./img", "img/", "data/../img", "../img", "/srv/yyl/img"} {
+		prefix, err := imagePrefix(dir)
+		if err != nil {
+			t.Errorf("%v: %v", dir, err)
+			continue
+		}
+
+		// the page links to images the same way as the report
+		src, err := url.Parse(filepath.ToSlash(filepath.Join(dir, "01.jpg")))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := base.ResolveReference(src).Path; !strings.HasPrefix(got, prefix) {
+			t.Errorf("%v: image at %v is not served under %v", dir, got, prefix)
+		}
+	}
+
+	for _, dir := range []string{".", "./", "..", "img/../.."} {
+		if _, err := imagePrefix(dir); err == nil {
+			t.Errorf("%v: want error", dir)
+		}
+	}
+}
@@ -0,0 +1,1105 @@
+package main
+
+// Chart based on: https://codepen.io/Dannzzor/pen/zoJGw
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/jcrussell/yyl/yyl"
+)
+
+// summaryColumns are the columns in the csv summary
+var summaryColumns = []struct {
+	Name  string
+	Value func(yyl.Stats) string
+}{
+	{"count", func(s yyl.Stats) string { return strconv.Itoa(s.Count) }},
+	{"mean", func(s yyl.Stats) string { return fmt.Sprintf("%.2f", s.Mean) }},
+	{"median", func(s yyl.Stats) string { return fmt.Sprintf("%.2f", s.Median) }},
+	{"mode", func(s yyl.Stats) string { return fmt.Sprint(s.Mode) }},
+	{"max", func(s yyl.Stats) string { return fmt.Sprint(s.Max) }},
+	{"longest", func(s yyl.Stats) string { return fmt.Sprintf("%.f", s.Longest.Hours()/24) }},
+	{"shortest", func(s yyl.Stats) string { return fmt.Sprintf("%.f", s.Shortest.Hours()/24) }},
+	{"average_gap", func(s yyl.Stats) string { return fmt.Sprintf("%.1f", s.AvgGap.Hours()/24) }},
+	{"max_per_week", func(s yyl.Stats) string { return strconv.Itoa(s.MaxPerWeek) }},
+	{"completed", func(s yyl.Stats) string { return strconv.Itoa(s.Completed) }},
+	{"percent_complete", func(s yyl.Stats) string { return fmt.Sprintf("%.1f", s.Percent) }},
+	{"total_spent", func(s yyl.Stats) string { return fmt.Sprintf("%.2f", s.TotalSpent) }},
+	{"average_price", func(s yyl.Stats) string { return fmt.Sprintf("%.2f", s.AvgPrice) }},
+}
+
+// writeSummary writes a csv row per person with their stats
+func writeSummary(w io.Writer, report yyl.Report) error {
+	cw := csv.NewWriter(w)
+
+	header := []string{"who"}
+	for _, col := range summaryColumns {
+		header = append(header, col.Name)
+	}
+	cw.Write(header)
+
+	for _, who := range report.People() {
+		row := []string{who}
+		for _, col := range summaryColumns {
+			row = append(row, col.Value(report.Stats[who]))
+		}
+		cw.Write(row)
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+var reAnchor = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+
+// anchor cleans s for use in an id so that links to a person's stats are
+// stable between runs. Names without any letters or digits, such as only
+// emoji, are hex encoded instead so that they don't all share an empty id.
+func anchor(s string) string {
+	res := strings.Trim(reAnchor.ReplaceAllString(strings.ToLower(s), "-"), "-")
+	if res == "" {
+		res = fmt.Sprintf("%x", s)
+	}
+
+	return res
+}
+
+// barStyle positions the fill of a css bar for the given percentage
+func barStyle(v float32) template.CSS {
+	// round the same as the label
+	v = float32(math.Round(float64(v)))
+
+	return template.CSS(fmt.Sprintf("height: %v%%; top: %v%%", v, 100-v))
+}
+
+// barWidth sizes the fill of a horizontal css bar for the given percentage
+func barWidth(v float32) template.CSS {
+	return template.CSS(fmt.Sprintf("width: %v%%", math.Round(float64(v))))
+}
+
+// svgBars draws a bar chart of percentages, one bar per ratio, with the
+// corresponding label underneath
+func svgBars(ratios []float32, labels []string, theme yyl.Theme) template.HTML {
+	var texts []string
+	for _, v := range ratios {
+		texts = append(texts, fmt.Sprintf("%2.f%%", v))
+	}
+
+	return svgChart(ratios, texts, labels, theme)
+}
+
+// svgLabeledBars draws a bar chart of percentages from bars, using their labels
+func svgLabeledBars(bars []yyl.LabeledBar, theme yyl.Theme) template.HTML {
+	var ratios []float32
+	var labels []string
+	for _, b := range bars {
+		ratios = append(ratios, b.Ratio)
+		labels = append(labels, b.Label)
+	}
+
+	return svgBars(ratios, labels, theme)
+}
+
+// svgMeans draws a bar chart of mean ratings out of max, one bar per mean, with
+// the corresponding label underneath
+func svgMeans(means []float32, max float32, labels []string, theme yyl.Theme) template.HTML {
+	var heights []float32
+	var texts []string
+	for _, v := range means {
+		heights = append(heights, percentOf(v, max))
+
+		// leave empty bars unlabeled
+		text := ""
+		if v != 0 {
+			text = fmt.Sprintf("%.1f", v)
+		}
+		texts = append(texts, text)
+	}
+
+	return svgChart(heights, texts, labels, theme)
+}
+
+// percentOf returns v as a percentage of max
+func percentOf(v, max float32) float32 {
+	if max == 0 {
+		return 0
+	}
+
+	return v / max * 100
+}
+
+// svgChart draws a bar chart with bars of the given heights, as percentages,
+// with text on each bar and the corresponding label underneath. Bars use the
+// same dimensions and colors as the css charts, falling back to theme when the
+// css variables are unset.
+func svgChart(heights []float32, texts, labels []string, theme yyl.Theme) template.HTML {
+	const (
+		barWidth  = 40
+		barGap    = 25
+		barHeight = 300
+		textSize  = 12
+	)
+
+	width := len(heights) * (barWidth + barGap)
+	height := barHeight + 2*textSize + 8
+
+	buf := &strings.Builder{}
+
+	fmt.Fprintf(buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="Lato,Verdana,sans-serif" font-size="%d">`, width, height, textSize)
+
+	for i, v := range heights {
+		x := i * (barWidth + barGap)
+		h := float32(barHeight) * v / 100
+
+		fmt.Fprintf(buf, `<rect x="%d" y="0" width="%d" height="%d" style="fill: var(--track, %v)"/>`, x, barWidth, barHeight, theme.Track)
+		fmt.Fprintf(buf, `<rect x="%d" y="%.1f" width="%d" height="%.1f" style="fill: var(--fill, %v)"/>`, x, barHeight-h, barWidth, h, theme.Fill)
+
+		if i < len(texts) {
+			fmt.Fprintf(buf, `<text x="%d" y="%.1f" style="fill: var(--text, %v)" text-anchor="middle">%v</text>`, x+barWidth/2, barHeight-h+textSize+2, theme.Text, template.HTMLEscapeString(texts[i]))
+		}
+
+		if i < len(labels) {
+			fmt.Fprintf(buf, `<text x="%d" y="%d" style="fill: var(--foreground)" text-anchor="middle">%v</text>`, x+barWidth/2, barHeight+textSize+4, template.HTMLEscapeString(labels[i]))
+		}
+	}
+
+	buf.WriteString(`</svg>`)
+
+	return template.HTML(buf.String())
+}
+
+// svgCalendar draws a heatmap with a cell per day from the first to the last
+// visit, a column per week. Days are shaded by the number of visits.
+func svgCalendar(s yyl.Stats) template.HTML {
+	const (
+		cell = 10
+		gap  = 2
+	)
+
+	visits := map[string]int{}
+	for _, rating := range s.Dated {
+		visits[rating.Date.Format("20060102")] += 1
+	}
+
+	// start on the sunday before the first visit
+	start := s.FirstDate.AddDate(0, 0, -int(s.FirstDate.Weekday()))
+	weeks := int(s.LastDate.Sub(start).Hours()/24/7) + 1
+
+	buf := &strings.Builder{}
+
+	fmt.Fprintf(buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d">`, weeks*(cell+gap), 7*(cell+gap))
+
+	for d := start; !d.After(s.LastDate); d = d.AddDate(0, 0, 1) {
+		if d.Before(s.FirstDate) {
+			continue
+		}
+
+		week := int(d.Sub(start).Hours() / 24 / 7)
+		x := week * (cell + gap)
+		y := int(d.Weekday()) * (cell + gap)
+
+		n := visits[d.Format("20060102")]
+		style := "fill: var(--track)"
+		if n == 1 {
+			style = "fill: var(--fill); fill-opacity: 0.6"
+		} else if n > 1 {
+			style = "fill: var(--fill)"
+		}
+
+		fmt.Fprintf(buf, `<rect x="%d" y="%d" width="%d" height="%d" style="%v"><title>%v: %d visits</title></rect>`, x, y, cell, cell, style, d.Format("Mon Jan 2 2006"), n)
+	}
+
+	buf.WriteString(`</svg>`)
+
+	return template.HTML(buf.String())
+}
+
+// svgSparkline draws a small line of the ratings in date order, scaled from 0
+// to each rating's max. Empty if there are fewer than two dated ratings.
+func svgSparkline(s yyl.Stats) template.HTML {
+	const (
+		width  = 120
+		height = 24
+		pad    = 2
+	)
+
+	if len(s.Dated) < 2 {
+		return ""
+	}
+
+	buf := &strings.Builder{}
+
+	fmt.Fprintf(buf, `<svg class="sparkline" xmlns="http://www.w3.org/2000/svg" width="%d" height="%d">`, width, height)
+	buf.WriteString(`<polyline style="fill: none; stroke: var(--fill); stroke-width: 1.5" points="`)
+
+	for i, rating := range s.Dated {
+		var v float32
+		if rating.Max > 0 {
+			v = rating.Value / rating.Max
+		}
+
+		x := pad + float32(i)/float32(len(s.Dated)-1)*(width-2*pad)
+		y := height - pad - v*(height-2*pad)
+
+		if i > 0 {
+			buf.WriteString(" ")
+		}
+		fmt.Fprintf(buf, `%.1f,%.1f`, x, y)
+	}
+
+	buf.WriteString(`"/></svg>`)
+
+	return template.HTML(buf.String())
+}
+
+// svgProgress draws the cumulative number of items rated over time, along with
+// a finish line at total
+func svgProgress(s yyl.Stats, total int) template.HTML {
+	const (
+		width  = 500
+		height = 200
+		pad    = 20
+	)
+
+	top := total
+	if len(s.Dated) > top {
+		top = len(s.Dated)
+	}
+	if top == 0 {
+		top = 1
+	}
+
+	span := s.LastDate.Sub(s.FirstDate).Hours()
+	if span == 0 {
+		span = 1
+	}
+
+	x := func(t time.Time) float64 {
+		return pad + t.Sub(s.FirstDate).Hours()/span*(width-2*pad)
+	}
+	y := func(n int) float64 {
+		return height - pad - float64(n)/float64(top)*(height-2*pad)
+	}
+
+	buf := &strings.Builder{}
+
+	fmt.Fprintf(buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="Lato,Verdana,sans-serif" font-size="12">`, width, height)
+
+	// finish line
+	fmt.Fprintf(buf, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" style="stroke: var(--foreground); stroke-dasharray: 4"/>`, pad, y(total), width-pad, y(total))
+	fmt.Fprintf(buf, `<text x="%d" y="%.1f" style="fill: var(--foreground)">%d items</text>`, pad, y(total)-4, total)
+
+	fmt.Fprintf(buf, `<polyline style="fill: none; stroke: var(--fill); stroke-width: 2" points="%.1f,%.1f`, x(s.FirstDate), y(0))
+	for i, rating := range s.Dated {
+		fmt.Fprintf(buf, ` %.1f,%.1f`, x(rating.Date), y(i+1))
+	}
+	buf.WriteString(`"/>`)
+
+	fmt.Fprintf(buf, `<text x="%d" y="%d" style="fill: var(--foreground)">%v</text>`, pad, height-4, s.FirstDate.Format("Jan 2 2006"))
+	fmt.Fprintf(buf, `<text x="%d" y="%d" text-anchor="end" style="fill: var(--foreground)">%v</text>`, width-pad, height-4, s.LastDate.Format("Jan 2 2006"))
+
+	buf.WriteString(`</svg>`)
+
+	return template.HTML(buf.String())
+}
+
+// statsArgs are passed to the stats template since it needs both the report
+// and a person's stats
+type statsArgs struct {
+	Report yyl.Report
+	Who    string
+	Stats  yyl.Stats
+}
+
+func newStatsArgs(r yyl.Report, who string, s yyl.Stats) statsArgs {
+	return statsArgs{r, who, s}
+}
+
+// render report to w in the given format. For html, fname overrides the
+// embedded template if it is not empty.
+func render(w io.Writer, format, fname string, report yyl.Report) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "\t")
+		return enc.Encode(report)
+	case "csv":
+		return writeSummary(w, report)
+	case "markdown":
+		// escape the start of html tags so that free text such as names and
+		// notes can't inject markup into the rendered markdown
+		escape := strings.NewReplacer("<", "\\<").Replace
+
+		funcs := texttemplate.FuncMap{
+			// also escape characters that would break table cells
+			"cell": func(s string) string {
+				return strings.Replace(escape(s), "|", "\\|", -1)
+			},
+			"escape": escape,
+		}
+
+		tmpl := texttemplate.Must(texttemplate.New("markdown").Funcs(funcs).Parse(markdown))
+		return tmpl.Execute(w, report)
+	default:
+		funcs := template.FuncMap{
+			"anchor":         anchor,
+			"barStyle":       barStyle,
+			"barWidth":       barWidth,
+			"indexLabels":    yyl.IndexLabels,
+			"monthLabels":    yyl.MonthLabels,
+			"percentOf":      percentOf,
+			"statsArgs":      newStatsArgs,
+			"svgBars":        svgBars,
+			"svgCalendar":    svgCalendar,
+			"svgLabeledBars": svgLabeledBars,
+			"svgMeans":       svgMeans,
+			"svgProgress":    svgProgress,
+			"svgSparkline":   svgSparkline,
+		}
+
+		// html/template strips comments so the stamp is written directly
+		if report.Stamp {
+			fmt.Fprintf(w, "<!-- generated by yyl %v at %v -->\n", report.Version, report.Generated.Format(time.RFC3339))
+		}
+
+		tmpl := template.Must(template.New("test").Funcs(funcs).Parse(page))
+		if fname == "" {
+			return tmpl.Execute(w, report)
+		}
+
+		// parse on top of the embedded template so that custom templates
+		// can use the "stats" template
+		tmpl, err := tmpl.ParseFiles(fname)
+		if err != nil {
+			return err
+		}
+
+		return tmpl.ExecuteTemplate(w, filepath.Base(fname), report)
+	}
+}
+
+var markdown = `# {{ escape .Title }}
+
+{{ escape .Intro }}
+
+## Ratings
+
+| # | Item |{{ range .People }} {{ cell . }} |{{ end }}
+|--:|------|{{ range .People }}---|{{ end }}
+{{- range $item := .Menu }}
+| {{ .Number }} | {{ cell .Name }} |
+	{{- range $who := $.People }}
+		{{- $rating := index $item.Ratings $who }}
+		{{- if $rating.Max }} {{ $rating.Value }}/{{ $rating.Max }}{{ with $rating.Label }} ({{ cell . }}){{ end }} |{{ else if (index $item.Skipped $who).Skipped }} skipped |{{ else }}  |{{ end }}
+	{{- end }}
+{{- end }}
+
+## Statistics
+{{ range $who := .People }}{{ with index $.Stats $who }}
+### {{ escape $who }}
+{{ if not .Count }}
+No ratings yet
+{{ else }}
+- Average rating: {{ printf "%.1f" .Mean }}/{{ .Max }}
+- Median rating: {{ printf "%.1f" .Median }}/{{ .Max }}
+- Most common rating: {{ .Mode }}
+{{- if .Completed }}
+- Favorite: #{{ .TopItem.Number }} {{ cell .TopItem.Name }} ({{ .TopValue }}/{{ .TopMax }})
+- Least favorite: #{{ .BottomItem.Number }} {{ cell .BottomItem.Name }} ({{ .BottomValue }}/{{ .BottomMax }})
+{{- end }}
+{{- if .Total }}
+- Rated {{ .Completed }} of {{ .Total }} items ({{ printf "%.f" .Percent }}%)
+{{- end }}
+{{- if .HasDate }}
+- Finished in {{ .FormattedTotal }}
+- Most visits in a week: {{ .MaxPerWeek }}
+- Busiest 4 weeks: {{ .FormattedPeakWindow }} with {{ .PeakWindowCount }} visits
+- Longest time between YYLs: {{ .FormattedLongest }} after {{ escape .LongestAfter }}
+- Shortest time between YYLs: {{ .FormattedShortest }}{{ with .ShortestAfter }} after {{ escape . }}{{ end }}
+- Average time between YYLs: {{ .FormattedAvgGap }}
+{{- end }}
+{{ end }}{{ end }}{{ end -}}
+`
+
+var page = `<html data-theme="{{ .Mode }}">
+<head>
+<meta charset="utf-8">
+<style>
+:root {
+	--fill: {{ .Theme.Fill }};
+	--track: {{ .Theme.Track }};
+	--text: {{ .Theme.Text }};
+	--background: #fff;
+	--foreground: #000;
+}
+:root[data-theme="dark"] {
+	--fill: #c85a8e;
+	--track: #333;
+	--text: #fff;
+	--background: #1b1b1b;
+	--foreground: #ddd;
+}
+@media (prefers-color-scheme: dark) {
+	:root[data-theme="auto"] {
+		--fill: #c85a8e;
+		--track: #333;
+		--text: #fff;
+		--background: #1b1b1b;
+		--foreground: #ddd;
+	}
+}
+body {
+	background: var(--background);
+	color: var(--foreground);
+}
+#theme-toggle {
+	float: right;
+}
+#verdict {
+	border-left: 5px solid var(--fill);
+	padding-left: 10px;
+}
+img {
+	width: 400px;
+}
+div.placeholder {
+	width: 400px;
+	height: 300px;
+	line-height: 300px;
+	text-align: center;
+	background: var(--track);
+}
+div.item {
+	float: left;
+	padding: 10px;
+}
+svg.sparkline {
+	vertical-align: middle;
+}
+#summary {
+	border-collapse: collapse;
+}
+#summary th, #summary td {
+	padding: 4px 10px;
+	text-align: right;
+}
+#summary th:first-child, #summary td:first-child {
+	text-align: left;
+}
+#summary th {
+	cursor: pointer;
+	border-bottom: 2px solid var(--fill);
+}
+h3.category {
+	clear: both;
+	padding-top: 10px;
+	border-bottom: 2px solid var(--fill);
+}
+span.badge {
+	font-size: 12px;
+	font-weight: normal;
+	padding: 2px 6px;
+	border-radius: 8px;
+	background: var(--track);
+	vertical-align: middle;
+}
+div.item summary {
+	font-weight: bold;
+	cursor: pointer;
+}
+#content {
+	padding: 10px;
+}
+div.ratings {
+	padding: 5px;
+}
+hr.clear, br.clear, p.clear {
+	clear: both;
+}
+
+.chart {
+	width: 500px;
+	background: var(--background);
+	overflow: hidden;
+	float: left;
+	padding: 10px;
+}
+
+.wide {
+	clear: both;
+	padding: 10px;
+}
+
+.chart.months {
+	width: 780px;
+}
+
+.chart.split {
+	width: 200px;
+}
+
+.progress-bar {
+	float: left;
+	width: 40px;
+	margin-right: 25px;
+}
+
+.progress-track {
+	position: relative;
+	width: 40px;
+	height: 300px;
+	background: var(--track);
+}
+
+.progress-track.horizontal {
+	width: 100%;
+	height: 20px;
+}
+
+.progress-fill.horizontal {
+	height: 100%;
+}
+
+.progress-label {
+	text-align: center;
+	font-family: "Lato","Verdana",sans-serif;
+	font-size: 12px;
+	line-height: 20px;
+}
+
+.progress-fill {
+	position: relative;
+	background: var(--fill);
+	height: 50%;
+	width: 40px;
+	color: var(--text);
+	text-align: center;
+	font-family: "Lato","Verdana",sans-serif;
+	font-size: 12px;
+	line-height: 20px;
+}
+</style>
+<script>
+function toggleTheme() {
+	var root = document.documentElement;
+	var dark = root.getAttribute("data-theme") == "dark" ||
+		(root.getAttribute("data-theme") == "auto" &&
+		window.matchMedia("(prefers-color-scheme: dark)").matches);
+
+	root.setAttribute("data-theme", dark ? "light" : "dark");
+}
+
+// sortTable sorts the rows of th's table by its column, toggling between
+// ascending and descending on each click
+function sortTable(th) {
+	var body = th.closest("table").tBodies[0];
+	var col = th.cellIndex;
+	var asc = th.getAttribute("data-order") != "asc";
+
+	var rows = Array.prototype.slice.call(body.rows);
+	rows.sort(function(a, b) {
+		var x = a.cells[col].getAttribute("data-sort");
+		var y = b.cells[col].getAttribute("data-sort");
+		var c = isNaN(x) || isNaN(y) ? x.localeCompare(y) : x - y;
+		return asc ? c : -c;
+	});
+	rows.forEach(function(row) {
+		body.appendChild(row);
+	});
+
+	th.setAttribute("data-order", asc ? "asc" : "desc");
+}
+</script>
+</head>
+<body>
+<button id="theme-toggle" onclick="toggleTheme()">Toggle dark mode</button>
+<div id="content">
+<h1>{{ .Title }}</h1>
+
+<p>
+{{ .Intro }}
+</p>
+
+<p>
+This page documents the results.
+</p>
+
+{{ if .GroupTotal }}
+<div id="group-progress">
+<div class="progress-track horizontal">
+	<div class="progress-fill horizontal" style="{{ barWidth .GroupPercent }}"></div>
+</div>
+<p>Group progress: {{ .GroupCompleted }} of {{ .GroupTotal }} item ratings ({{ printf "%.f" .GroupPercent }}%)</p>
+</div>
+{{ end }}
+
+<div id="toc">
+<h2>Contents</h2>
+<p>Items:
+{{- range .Menu }} <a href="#item-{{ .Number }}" title="{{ .Name }}">#{{ .Number }}</a>{{ end }}</p>
+{{- if .Reviewers }}
+<p>Statistics:
+{{- range $i, $v := .Reviewers }}{{ if $i }},{{ end }} <a href="#stats-{{ anchor .Name }}">{{ .Name }}</a>{{ end }}</p>
+{{- end }}
+</div>
+
+{{ if .Reviewers }}
+<h2>Summary</h2>
+<table id="summary">
+<thead>
+<tr>
+	<th onclick="sortTable(this)">Name</th>
+	<th onclick="sortTable(this)">Rated</th>
+	<th onclick="sortTable(this)">Average</th>
+	<th onclick="sortTable(this)">Median</th>
+	<th onclick="sortTable(this)">Longest gap</th>
+	<th onclick="sortTable(this)">Most per week</th>
+	<th onclick="sortTable(this)">Finished</th>
+</tr>
+</thead>
+<tbody>
+{{- range .Reviewers }}{{ $who := .Name }}{{ with .Stats }}
+<tr>
+	<td data-sort="{{ $who }}"><a href="#stats-{{ anchor $who }}">{{ $who }}</a></td>
+	<td data-sort="{{ .Count }}">{{ .Count }}</td>
+	<td data-sort="{{ percentOf .Mean .Max }}">{{ printf "%.1f" .Mean }}/{{ .Max }}</td>
+	<td data-sort="{{ percentOf .Median .Max }}">{{ printf "%.1f" .Median }}/{{ .Max }}</td>
+	{{- if .HasDate }}
+	<td data-sort="{{ .Longest.Hours }}">{{ .FormattedLongest }}</td>
+	<td data-sort="{{ .MaxPerWeek }}">{{ .MaxPerWeek }}</td>
+	{{- else }}
+	<td data-sort="-1">&mdash;</td>
+	<td data-sort="-1">&mdash;</td>
+	{{- end }}
+	{{- if and .HasDate .Total (eq .Completed .Total) }}
+	<td data-sort="{{ .LastDate.Format "20060102" }}">{{ .LastDate.Format "Mon Jan 2 2006" }}</td>
+	{{- else }}
+	<td data-sort="99999999">&mdash;</td>
+	{{- end }}
+</tr>
+{{- end }}{{ end }}
+</tbody>
+</table>
+{{ end }}
+
+{{ if .Favorite }}
+<div id="verdict">
+<h2>Group verdict</h2>
+<p>Favorite: #{{ .Favorite.Number }} {{ .Favorite.Name }} ({{ printf "%.2f" .Favorite.Mean }}/{{ .Scale }} across {{ .Favorite.RaterCount }} ratings)</p>
+<p>Least favorite: #{{ .LeastFavorite.Number }} {{ .LeastFavorite.Name }} ({{ printf "%.2f" .LeastFavorite.Mean }}/{{ .Scale }} across {{ .LeastFavorite.RaterCount }} ratings)</p>
+{{- if .Adventurous }}
+<p>Most adventurous:
+{{- range $i, $v := .Adventurous }}{{ if $i }},{{ end }} {{ $v }}{{ end }} ({{ .AdventurousCount }} items rated)</p>
+<p>Most cautious:
+{{- range $i, $v := .Cautious }}{{ if $i }},{{ end }} {{ $v }}{{ end }} ({{ .CautiousCount }} items rated)</p>
+{{- end }}
+{{- with .MostRepeated }}
+<p>Most revisited: #{{ .Number }} {{ .Name }} ({{ $.MostRepeatedVisits }} repeat visits)</p>
+{{- end }}
+</div>
+{{ end }}
+
+<h2>Ratings</h2>
+<p>
+Each diner applied a rating system according to his own preference. In all cases, a higher number is better.
+</p>
+{{- if not .Anonymized }}
+<ul>
+<li>Devin: 0, indicating "would not eat again", or 1, indicating "would eat again".
+<li>Evan: A decimal number between 1 and 5
+<li>John: A decimal number between 1 and 5. A score of 0 indicates that John did not try the dish before moving.
+<li>Jon: An integer number between 1 and 5.
+</ul>
+{{- end }}
+
+<div id="items">
+{{ range .Categories }}
+{{- if .Name }}
+<h3 class="category">{{ .Name }}</h3>
+{{ end }}
+{{- range .Items }}
+	<div class="item" id="item-{{ .Number }}">
+	{{- if $.Collapse }}
+	<details>
+	<summary>#{{.Number}}: {{.Name}} {{ template "ratingCount" . }}</summary>
+	{{- else }}
+	<h3>#{{.Number}}: {{.Name}} {{ template "ratingCount" . }}</h3>
+	{{- end }}
+	{{- if .HasImage }}
+	<img src="{{ .Src }}" title="{{.Name}}" />
+	{{- else }}
+	<div class="placeholder" title="{{ .Name }}">{{ .Name }}</div>
+	{{- end }}
+	<div class="ratings">
+		<ul>
+		{{- range .SortedRatings }}{{ $who := .Name }}{{ $rating := .Rating }}
+			<li>
+				{{- $who }}: {{ $rating.Value }}/{{ $rating.Max }}
+				{{- with $rating.Label }} ({{ . }}){{ end }}
+				{{- if not $rating.Date.IsZero }} on {{ $rating.FormattedDate }}{{ end }}
+				{{- if $rating.RelativeDate }} ({{ $rating.RelativeDate }}){{ end }}
+				{{- if $rating.Note }} &mdash; <q>{{ $rating.Note }}</q>{{ end -}}
+			</li>
+		{{- end }}
+		{{- range $who, $rating := .Skipped }}
+			<li>
+				{{- $who }}: skipped
+				{{- if $rating.Note }} &mdash; <q>{{ $rating.Note }}</q>{{ end -}}
+			</li>
+		{{- end }}
+		</ul>
+	</div>
+	{{- if $.Collapse }}
+	</details>
+	{{- end }}
+	</div>
+{{ end }}
+{{- end }}
+</div>
+
+<hr class="clear" />
+
+{{ if .Top }}
+<h2>Rankings</h2>
+
+<h3>Top items</h3>
+<ol>
+{{- range .Top }}
+	<li>#{{ .Number }}: {{ .Name }} ({{ printf "%.2f" .Mean }}/{{ $.Scale }})</li>
+{{- end }}
+</ol>
+
+<h3>Bottom items</h3>
+<ol>
+{{- range .Bottom }}
+	<li>#{{ .Number }}: {{ .Name }} ({{ printf "%.2f" .Mean }}/{{ $.Scale }})</li>
+{{- end }}
+</ol>
+{{ end }}
+
+{{ if .BestValue }}
+<h3>Best value</h3>
+<ol>
+{{- range .BestValue }}
+	<li>#{{ .Number }}: {{ .Name }} ({{ printf "%.2f" .Mean }}/{{ $.Scale }} for {{ $.Currency }}{{ printf "%.2f" .Price }})</li>
+{{- end }}
+</ol>
+{{- if .Unpriced }}
+<p>{{ .Unpriced }} rated items have no price data.</p>
+{{- end }}
+{{ end -}}
+
+{{ if .Controversial }}
+<h3>Most controversial items</h3>
+<ol>
+{{- range .Controversial }}
+	<li>#{{ .Number }}: {{ .Name }} (variance {{ printf "%.2f" .Variance }} across {{ .RaterCount }} ratings)</li>
+{{- end }}
+</ol>
+{{ end }}
+
+{{ if .Finishers }}
+<h2>Finishers</h2>
+<ol>
+{{- range .Finishers }}
+	<li>{{ .Name }}{{ if not .Date.IsZero }} on {{ .FormattedDate }}{{ end }}</li>
+{{- end }}
+</ol>
+{{- if .Unfinished }}
+<p>Still going:
+{{- range $i, $v := .Unfinished }}{{ if $i }},{{ end }} {{ .Name }} ({{ printf "%.f" .Percent }}%){{ end }}</p>
+{{- end }}
+{{ else if .Unfinished }}
+<h2>Finishers</h2>
+<p>Nobody has finished yet:
+{{- range $i, $v := .Unfinished }}{{ if $i }},{{ end }} {{ .Name }} ({{ printf "%.f" .Percent }}%){{ end }}</p>
+{{ end }}
+
+{{ if gt (len .Stats) 1 }}
+<h2>Agreement</h2>
+<p>Correlation between ratings for items both people tried.</p>
+<table>
+<tr>
+	<th></th>
+	{{- range .People }}
+	<th>{{ . }}</th>
+	{{- end }}
+</tr>
+{{- range $a := .People }}
+<tr>
+	<th>{{ $a }}</th>
+	{{- range $b := $.People }}
+	<td>{{ $.Correlation $a $b }}</td>
+	{{- end }}
+</tr>
+{{- end }}
+</table>
+{{ end }}
+
+<h2>Statistics</h2>
+
+{{ if and .Group.Count (gt (len .Stats) 1) }}
+	<h3 id="stats-everyone">Everyone</h3>
+	<div class="chart">
+	{{- if .ShowChart "rating" }}
+	<h4>Rating</h4>
+	{{ template "ratingBars" (statsArgs $ "Everyone" .Group) }}
+	{{- end }}
+	<p class="clear">Average rating: {{ printf "%.1f" .Group.Mean }}/{{ .Scale }}</p>
+	<p>Median rating: {{ printf "%.1f" .Group.Median }}/{{ .Scale }}</p>
+	</div>
+
+	<br class="clear" />
+{{ end }}
+
+{{ range .Reviewers }}{{ $who := .Name }}{{ $stats := .Stats }}
+	<h3 id="stats-{{ anchor $who }}">{{ $who }} {{ svgSparkline $stats }}</h3>
+	{{ template "stats" (statsArgs $ $who $stats) }}
+
+	{{ range index $.Years $who }}
+	<h3 id="stats-{{ anchor $who }}-{{ .Year }}">{{ $who }} in {{ .Year }}</h3>
+	{{ template "stats" (statsArgs $ $who .Stats) }}
+	{{ end }}
+{{ end }}
+
+<hr class="clear" />
+<footer>Generated {{ .FormattedGenerated }}</footer>
+</div>
+</body>
+</html>
+{{- define "stats" }}{{ $who := .Who }}{{ with .Stats }}
+{{- if not .Count }}
+	<p>No ratings yet</p>
+{{- else }}
+
+	{{ if .Total }}
+		<p>Rated {{ .Completed }} of {{ .Total }} items ({{ printf "%.f" .Percent }}%)
+		{{- if .Skipped }}, {{ .Skipped }} skipped{{ end }}</p>
+	{{ end }}
+
+	{{ if .Completed }}
+		<p>{{ $who }}'s favorite: #{{ .TopItem.Number }} {{ .TopItem.Name }} ({{ .TopValue }}/{{ .TopMax }})</p>
+		<p>{{ $who }}'s least favorite: #{{ .BottomItem.Number }} {{ .BottomItem.Name }} ({{ .BottomValue }}/{{ .BottomMax }})</p>
+	{{ end }}
+
+	{{ with .Validate }}
+		<details class="notes">
+		<summary>Data notes</summary>
+		<ul>
+		{{- range . }}
+			<li>{{ . }}</li>
+		{{- end }}
+		</ul>
+		</details>
+	{{ end }}
+
+	{{ with .MostRepeated }}
+		<p>{{ $who }}'s most revisited: #{{ .Number }} {{ .Name }} ({{ $.Stats.MostRepeatedVisits }} repeat visits)</p>
+	{{ end }}
+
+	{{ if .Missing }}
+		<p>{{ $who }} hasn't rated:
+		{{- range $i, $v := .Missing }}{{ if $i }},{{ end }} #{{ .Number }} {{ .Name }}{{ end }}</p>
+	{{ end }}
+
+	{{ if .HasDate }}
+		<p>Finished in {{ .FormattedTotal }}</p>
+		<p>Most visits in a week: {{ .MaxPerWeek }}</p>
+		<p>Busiest 4 weeks: {{ .FormattedPeakWindow }} with {{ .PeakWindowCount }} visits</p>
+		<p>Longest time between YYLs: {{ .FormattedLongest }} after {{ .LongestAfter }}</p>
+		<p>Shortest time between YYLs: {{ .FormattedShortest }}{{ with .ShortestAfter }} after {{ . }}{{ end }}</p>
+		<p>Average time between YYLs: {{ .FormattedAvgGap }}</p>
+		{{- if .Trend }}
+		<p>
+			{{- if eq .Trend "up" }}Ratings trending up &uarr;
+			{{- else if eq .Trend "down" }}Ratings trending down &darr;
+			{{- else }}Ratings stayed flat &rarr;{{ end }} ({{ .FormattedSlope }})</p>
+		{{- end }}
+
+		{{ if $.Report.ShowChart "calendar" }}
+		<div class="wide">
+		<h4>Calendar</h4>
+		{{ svgCalendar . }}
+		</div>
+		{{ end }}
+
+		{{ if $.Report.ShowChart "progress" }}
+		<div class="wide">
+		<h4>Progress</h4>
+		{{ svgProgress . (len $.Report.Menu) }}
+		</div>
+		{{ end }}
+
+		{{ if $.Report.ShowChart "weekday" }}
+		<div class="chart">
+		<h4>Day of Week</h4>
+		{{ if eq $.Report.Charts "svg" }}
+			{{ svgLabeledBars .WeekdayBars $.Report.Theme }}
+		{{ else }}
+		{{ range .WeekdayBars }}
+			<div class="progress-bar">
+				<div class="progress-track">
+					<div class="progress-fill" style="{{ barStyle .Ratio }}" title="{{ .Count }} visits">
+						<span>{{ printf "%2.f" .Ratio }}%</span>
+					</div>
+				</div>
+				<div class="progress-label">{{ .Label }}</div>
+			</div>
+		{{ end }}
+		{{ end }}
+		</div>
+		{{ end }}
+
+		{{ if $.Report.ShowChart "month" }}
+		<div class="chart months">
+		<h4>Month</h4>
+		{{ if eq $.Report.Charts "svg" }}
+			{{ svgBars .MonthRatios monthLabels $.Report.Theme }}
+		{{ else }}
+		{{ range .MonthBars }}
+			<div class="progress-bar">
+				<div class="progress-track">
+					<div class="progress-fill" style="{{ barStyle .Ratio }}" title="{{ .Count }} visits">
+						<span>{{ printf "%2.f" .Ratio }}%</span>
+					</div>
+				</div>
+				<div class="progress-label">{{ .Label }}</div>
+			</div>
+		{{ end }}
+		{{ end }}
+		</div>
+		{{ end }}
+
+		{{ if $.Report.ShowChart "weekday-rating" }}
+		<div class="chart">
+		<h4>Rating by Day of Week</h4>
+		{{ if eq $.Report.Charts "svg" }}
+			{{ svgMeans .WeekdayMeanRatings .Max .WeekdayLabels $.Report.Theme }}
+		{{ else }}
+		{{ range $k, $v := .WeekdayMeanRatings }}
+			<div class="progress-bar">
+				<div class="progress-track">
+					<div class="progress-fill" style="{{ barStyle (percentOf $v $.Stats.Max) }}">
+						<span>{{ if $v }}{{ printf "%.1f" $v }}{{ end }}</span>
+					</div>
+				</div>
+				<div class="progress-label">{{ index $.Stats.WeekdayLabels $k }}</div>
+			</div>
+		{{ end }}
+		{{ end }}
+		</div>
+		{{ end }}
+
+		{{ if $.Report.ShowChart "gaps" }}
+		<div class="chart split">
+		<h4>Days Between Visits</h4>
+		{{ if eq $.Report.Charts "svg" }}
+			{{ svgLabeledBars .GapBars $.Report.Theme }}
+		{{ else }}
+		{{ range .GapBars }}
+			<div class="progress-bar">
+				<div class="progress-track">
+					<div class="progress-fill" style="{{ barStyle .Ratio }}" title="{{ .Count }} gaps">
+						<span>{{ printf "%2.f" .Ratio }}%</span>
+					</div>
+				</div>
+				<div class="progress-label">{{ .Label }}</div>
+			</div>
+		{{ end }}
+		{{ end }}
+		</div>
+		{{ end }}
+
+		{{ if $.Report.ShowChart "weekend" }}
+		<div class="chart split">
+		<h4>Weekday vs Weekend</h4>
+		{{ if eq $.Report.Charts "svg" }}
+			{{ svgLabeledBars .WeekendBars $.Report.Theme }}
+		{{ else }}
+		{{ range .WeekendBars }}
+			<div class="progress-bar">
+				<div class="progress-track">
+					<div class="progress-fill" style="{{ barStyle .Ratio }}" title="{{ .Count }} visits">
+						<span>{{ printf "%2.f" .Ratio }}%</span>
+					</div>
+				</div>
+				<div class="progress-label">{{ .Label }}</div>
+			</div>
+		{{ end }}
+		{{ end }}
+		<p class="clear">Average rating: {{ printf "%.1f" .WeekdayMean }} on weekdays, {{ printf "%.1f" .WeekendMean }} on weekends</p>
+		</div>
+		{{ end }}
+	{{ end }}
+
+	{{ if and (gt (len .CategoryNames) 1) ($.Report.ShowChart "category") }}
+	<div class="chart">
+	<h4>Rating by Category</h4>
+	{{ if eq $.Report.Charts "svg" }}
+		{{ svgMeans .CategoryMeanRatings .Max .CategoryNames $.Report.Theme }}
+	{{ else }}
+	{{ range $k, $v := .CategoryMeanRatings }}
+		<div class="progress-bar">
+			<div class="progress-track">
+				<div class="progress-fill" style="{{ barStyle (percentOf $v $.Stats.Max) }}">
+					<span>{{ printf "%.1f" $v }}</span>
+				</div>
+			</div>
+			<div class="progress-label">{{ index $.Stats.CategoryNames $k }}</div>
+		</div>
+	{{ end }}
+	{{ end }}
+	<p class="clear">{{ $who }} rates {{ .TopCategory }} highest</p>
+	</div>
+	{{ end }}
+
+	<div class="chart">
+	{{- if $.Report.ShowChart "rating" }}
+	<h4>Rating</h4>
+	{{ template "ratingBars" $ }}
+	{{- end }}
+	<p class="clear">Average rating: {{ printf "%.1f" .Mean }}/{{ .Max }}</p>
+	{{- if .HasPrice }}
+	<p>Total spent: {{ $.Report.Currency }}{{ printf "%.2f" .TotalSpent }} (average {{ $.Report.Currency }}{{ printf "%.2f" .AvgPrice }} per item)</p>
+	{{- end }}
+	<p>Median rating: {{ printf "%.1f" .Median }}/{{ .Max }}</p>
+	<p>Most common rating:
+		{{- if .ModeTies }}
+		<span title="tied with {{ range $i, $v := .ModeTies }}{{ if $i }}, {{ end }}{{ $v }}{{ end }}">{{ .Mode }}*</span>
+		{{- else }} {{ .Mode }}{{ end -}}
+	</p>
+	</div>
+
+	<br class="clear" />
+{{ end }}{{ end }}{{ end }}
+
+{{- define "ratingCount" -}}
+	<span class="badge">{{ len .Ratings }} {{ if eq (len .Ratings) 1 }}rating{{ else }}ratings{{ end }}</span>
+{{- end }}
+{{- define "ratingBars" }}{{ with .Stats }}
+	{{ if eq $.Report.Charts "svg" }}
+		{{ svgBars .RatingRatios .RatingLabels $.Report.Theme }}
+	{{ else }}
+	{{ range .RatingBars }}
+		<div class="progress-bar">
+			<div class="progress-track">
+				<div class="progress-fill" style="{{ barStyle .Ratio }}" title="{{ .Count }} ratings">
+					<span>{{ printf "%2.f" .Ratio }}%</span>
+				</div>
+			</div>
+			<div class="progress-label">{{ .Label }}</div>
+		</div>
+	{{ end }}
+	{{ end }}
+{{- end }}{{ end }}`
@@ -0,0 +1,402 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jcrussell/yyl/yyl"
+)
+
+// writeFiles writes each of the files, keyed by slash-separated path, to a
+// temporary directory and returns it
+func writeFiles(t testing.TB, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, contents := range files {
+		fname := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fname), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fname, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+// testConfig returns the default config for the menu.csv, ratings, and img in
+// dir, generated at a fixed time so that the output is reproducible
+func testConfig(t testing.TB, dir string) yyl.Config {
+	t.Helper()
+
+	renderer, chartSet, err := parseCharts(*f_charts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	theme := yyl.Theme{
+		Fill:  *f_fill,
+		Track: *f_track,
+		Text:  *f_text,
+	}
+
+	cfg := configFromFlags(yyl.CSVOptions{Comma: ','}, yyl.DateLayouts, theme)
+	cfg.Charts, cfg.ChartSet = renderer, chartSet
+	cfg.Menu = filepath.Join(dir, "menu.csv")
+	cfg.Ratings = filepath.Join(dir, "ratings")
+	cfg.Img = filepath.Join(dir, "img")
+	cfg.Now = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	return cfg
+}
+
+// renderString builds the report for cfg and renders it in format
+func renderString(t testing.TB, cfg yyl.Config, format string) string {
+	t.Helper()
+
+	report, err := yyl.BuildReport(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := render(buf, format, "", report); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.String()
+}
+
+func TestRenderMarkdownSkipped(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"menu.csv":          "number,name\n1,A\n2,B\n",
+		"ratings/alice.csv": "number,date,rating,max\n1,20150106,7,10\n2,20150107,,10\n",
+		"ratings/bob.csv":   "number,date,rating,max\n1,20150106,5,10\n",
+	})
+
+	out := renderString(t, testConfig(t, dir), "markdown")
+
+	// bob hasn't rated item 2, which is not the same as skipping it
+	if want := "| 2 | B | skipped |  |\n"; !strings.Contains(out, want) {
+		t.Errorf("want row %q in:\n%v", want, out)
+	}
+}
+
+func TestRenderEscapesFreeText(t *testing.T) {
+	const xss = "<script>alert(1)</script>"
+	const item = "<img src=x onerror=alert(1)>"
+
+	dir := writeFiles(t, map[string]string{
+		"menu.csv":          "number,name\n1,A\n2," + item + "\n",
+		"names.csv":         "file,name\nbob," + xss + "\n",
+		"ratings/bob.csv":   "number,date,rating,max,note\n1,20150106,7,10," + xss + "\n2,20150110,3,10\n",
+		"ratings/alice.csv": "number,date,rating,max\n1,20150106,5,10\n",
+	})
+
+	cfg := testConfig(t, dir)
+	cfg.Names = filepath.Join(dir, "names.csv")
+	cfg.Title = xss
+	cfg.Intro = xss
+
+	html := renderString(t, cfg, "html")
+	if strings.Contains(html, xss) {
+		t.Errorf("unescaped script in html:\n%v", html)
+	}
+	if n := strings.Count(html, "&lt;script&gt;"); n < 4 {
+		t.Errorf("want the title, intro, name, and note escaped, got %v escaped", n)
+	}
+	if strings.Contains(html, item) {
+		t.Errorf("unescaped item name in html:\n%v", html)
+	}
+
+	md := renderString(t, cfg, "markdown")
+	if strings.Contains(md, xss) {
+		t.Errorf("unescaped tag in markdown:\n%v", md)
+	}
+	if n := strings.Count(md, `\<script>`); n < 3 {
+		t.Errorf("want the title, intro, and name escaped, got %v escaped", n)
+	}
+	// the ratings table, favorites, and gaps
+	if strings.Contains(strings.Replace(md, `\`+item, "", -1), item) {
+		t.Errorf("unescaped item name in markdown:\n%v", md)
+	}
+	if n := strings.Count(md, `\`+item); n < 3 {
+		t.Errorf("want the item name escaped everywhere, got %v escaped", n)
+	}
+}
+
+func TestRenderWeekStartFromReport(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"menu.csv":        "number,name\n1,A\n",
+		"ratings/bob.csv": "number,date,rating,max\n1,20150106,7,10\n",
+	})
+
+	// the -week-start flag is left as sunday
+	cfg := testConfig(t, dir)
+	cfg.WeekStart = time.Monday
+
+	for _, charts := range []string{"css", "svg"} {
+		cfg.Charts = charts
+		out := renderString(t, cfg, "html")
+
+		for _, chart := range []string{"<h4>Day of Week</h4>", "<h4>Rating by Day of Week</h4>"} {
+			i := strings.Index(out, chart)
+			if i < 0 {
+				t.Fatalf("%v: missing %v", charts, chart)
+			}
+
+			mon, sun := strings.Index(out[i:], ">Mon<"), strings.Index(out[i:], ">Sun<")
+			if mon < 0 || sun < mon {
+				t.Errorf("%v: %v should start on Monday", charts, chart)
+			}
+		}
+	}
+}
+
+func BenchmarkRenderEmbeddedImages(b *testing.B) {
+	const items = 100
+
+	img := bytes.Repeat([]byte{0xff, 0xd8, 0x00, 0x42}, 128<<10)
+
+	menu := &strings.Builder{}
+	ratings := &strings.Builder{}
+	menu.WriteString("number,name\n")
+	ratings.WriteString("number,date,rating,max\n")
+	files := map[string]string{}
+
+	for i := 1; i <= items; i++ {
+		fmt.Fprintf(menu, "%v,Item %v\n", i, i)
+		fmt.Fprintf(ratings, "%v,2015-01-%02d,%v,10\n", i, i%28+1, i%11)
+		files[fmt.Sprintf("img/%02d.jpg", i)] = string(img)
+	}
+
+	files["menu.csv"] = menu.String()
+	files["ratings/bob.csv"] = ratings.String()
+
+	cfg := testConfig(b, writeFiles(b, files))
+	cfg.EmbedImages = true
+
+	report, err := yyl.BuildReport(cfg)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := render(io.Discard, "html", "", report); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestRenderEmptyRatingsFile(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"menu.csv":          "number,name\n1,A\n",
+		"ratings/alice.csv": "",
+		"ratings/bob.csv":   "number,date,rating,max\n",
+	})
+
+	cfg := testConfig(t, dir)
+	cfg.Strict = true
+
+	report, err := yyl.BuildReport(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, who := range []string{"Alice", "Bob"} {
+		s, ok := report.Stats[who]
+		if !ok {
+			t.Errorf("missing %v", who)
+		} else if s.Count != 0 {
+			t.Errorf("%v: want no ratings, got %v", who, s.Count)
+		}
+	}
+
+	for _, format := range []string{"html", "markdown"} {
+		buf := &bytes.Buffer{}
+		if err := render(buf, format, "", report); err != nil {
+			t.Fatal(err)
+		}
+
+		if n := strings.Count(buf.String(), "No ratings yet"); n != 2 {
+			t.Errorf("%v: want no ratings yet for both, got %v", format, n)
+		}
+	}
+}
+
+func TestRenderEmptyNoNaN(t *testing.T) {
+	// the sort script calls isNaN so only match the whole word
+	nan := regexp.MustCompile(`\bNaN\b`)
+
+	for name, files := range map[string]map[string]string{
+		"no people": {
+			"menu.csv":          "number,name\n1,A\n",
+			"ratings/README.md": "one file per person\n",
+		},
+		"no ratings": {
+			"menu.csv":        "number,name\n1,A\n",
+			"ratings/bob.csv": "number,date,rating,max\n",
+		},
+		"only skipped": {
+			"menu.csv":        "number,name\n1,A\n",
+			"ratings/bob.csv": "number,date,rating,max\n1,20150106,,10\n",
+		},
+	} {
+		cfg := testConfig(t, writeFiles(t, files))
+
+		for _, charts := range []string{"css", "svg"} {
+			cfg.Charts = charts
+
+			for _, format := range []string{"html", "markdown", "json", "csv"} {
+				out := renderString(t, cfg, format)
+
+				if nan.MatchString(out) {
+					t.Errorf("%v: NaN in %v %v output:\n%v", name, charts, format, out)
+				}
+			}
+		}
+	}
+}
+
+func TestRenderNonASCII(t *testing.T) {
+	const name = "Phở Gà, 宫保鸡丁, Crème brûlée"
+
+	dir := writeFiles(t, map[string]string{
+		"menu.csv":          "number,name\n1,\"" + name + "\"\n",
+		"ratings/zoë.csv":   "number,date,rating,max,note\n1,20150106,7,10,très bon\n",
+		"ratings/alice.csv": "number,date,rating,max\n1,20150106,5,10\n",
+	})
+
+	report, err := yyl.BuildReport(testConfig(t, dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, format := range []string{"html", "markdown", "csv", "json"} {
+		fname := filepath.Join(t.TempDir(), "out")
+		if err := writeOutput(fname, func(w io.Writer) error {
+			return render(w, format, "", report)
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := os.ReadFile(fname)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := string(data)
+
+		if !utf8.ValidString(out) {
+			t.Errorf("%v: invalid utf-8", format)
+		}
+
+		if !strings.Contains(out, "Zoë") {
+			t.Errorf("%v: missing name", format)
+		}
+		// the csv summary doesn't include the menu
+		if format != "csv" && !strings.Contains(out, name) {
+			t.Errorf("%v: missing menu item", format)
+		}
+	}
+
+	if out := renderString(t, testConfig(t, dir), "html"); !strings.Contains(out, `<meta charset="utf-8">`) {
+		t.Errorf("missing charset")
+	}
+}
+
+func TestRenderStampFromReport(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"menu.csv":        "number,name\n1,A\n",
+		"ratings/bob.csv": "number,date,rating,max\n1,20150106,7,10\n",
+	})
+
+	// the -stamp flag is left unset
+	cfg := testConfig(t, dir)
+	cfg.Stamp = true
+	cfg.Version = "v1.2.3"
+
+	out := renderString(t, cfg, "html")
+	if want := "<!-- generated by yyl v1.2.3 at 2024-01-01T00:00:00Z -->\n"; !strings.HasPrefix(out, want) {
+		t.Errorf("want stamp %q, got %q", want, out[:strings.Index(out, "\n")+1])
+	}
+
+	cfg.Stamp = false
+	if out := renderString(t, cfg, "html"); strings.Contains(out, "generated by yyl") {
+		t.Errorf("unexpected stamp")
+	}
+}
+
+func TestRenderNoShortestGap(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"menu.csv":        "number,name\n1,A\n2,B\n",
+		"ratings/bob.csv": "number,date,rating,max\n1,20150106,7,10\n",
+	})
+
+	report, err := yyl.BuildReport(testConfig(t, dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := report.Stats["Bob"].FormattedShortest; got != "n/a" {
+		t.Errorf("want n/a, got %q", got)
+	}
+
+	for format, want := range map[string]string{
+		"html":     "<p>Shortest time between YYLs: n/a</p>",
+		"markdown": "- Shortest time between YYLs: n/a\n",
+	} {
+		buf := &bytes.Buffer{}
+		if err := render(buf, format, "", report); err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("%v: want %q", format, want)
+		}
+	}
+}
+
+func TestAnchor(t *testing.T) {
+	for _, c := range []struct {
+		name, want string
+	}{
+		{"Bob", "bob"},
+		{"Mary Jo", "mary-jo"},
+		{"Zoë", "zoë"},
+		{"Zoe", "zoe"},
+		{"宫保", "宫保"},
+		{"鸡丁", "鸡丁"},
+		{"🍜", "f09f8d9c"},
+		{"🍣", "f09f8da3"},
+	} {
+		if got := anchor(c.name); got != c.want {
+			t.Errorf("anchor(%q) = %q, want %q", c.name, got, c.want)
+		}
+	}
+
+	dir := writeFiles(t, map[string]string{
+		"menu.csv":        "number,name\n1,A\n",
+		"ratings/zoë.csv": "number,date,rating,max\n1,20150106,7,10\n",
+		"ratings/宫保.csv":  "number,date,rating,max\n1,20150106,5,10\n",
+	})
+
+	out := renderString(t, testConfig(t, dir), "html")
+	for _, id := range []string{`id="stats-zoë"`, `id="stats-宫保"`} {
+		if !strings.Contains(out, id) {
+			t.Errorf("missing %v", id)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package yyl
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// Cache holds the parsed menu and ratings files so that they are only re-read
+// when their modification time or size changes. It is safe for concurrent use.
+// A nil Cache is valid and reads the files every time.
+//
+// Entries are keyed by file name only, callers should Reset the cache if the
+// options used to parse the files change.
+type Cache struct {
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	modTime time.Time
+	size    int64
+	value   interface{}
+}
+
+// NewCache returns an empty cache
+func NewCache() *Cache {
+	return &Cache{
+		entries: map[string]cacheEntry{},
+	}
+}
+
+// Invalidate drops the cached contents of fname, if any
+func (c *Cache) Invalidate(fname string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range []string{"menu:" + fname, "ratings:" + fname, "combined:" + fname} {
+		delete(c.entries, key)
+	}
+}
+
+// Reset drops everything in the cache
+func (c *Cache) Reset() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = map[string]cacheEntry{}
+}
+
+// load returns the cached value for key if fname has not changed since it was
+// stored, otherwise it calls fn and caches the result. Errors are not cached.
+func (c *Cache) load(key, fname string, fn func() (interface{}, error)) (interface{}, error) {
+	if c == nil {
+		return fn()
+	}
+
+	fi, err := os.Stat(fname)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+
+	if ok && entry.modTime.Equal(fi.ModTime()) && entry.size == fi.Size() {
+		return entry.value, nil
+	}
+
+	v, err := fn()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.entries[key] = cacheEntry{
+		modTime: fi.ModTime(),
+		size:    fi.Size(),
+		value:   v,
+	}
+	c.mu.Unlock()
+
+	return v, nil
+}
+
+// menu returns a copy of the menu in fname, see ReadMenu
+func (c *Cache) menu(fname string, opts CSVOptions) ([]MenuItem, error) {
+	v, err := c.load("menu:"+fname, fname, func() (interface{}, error) {
+		return ReadMenu(fname, opts)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// the report attaches images and ratings to the menu items
+	menu := append([]MenuItem(nil), v.([]MenuItem)...)
+	for i := range menu {
+		menu[i].Ratings = map[string]Rating{}
+		menu[i].Skipped = nil
+	}
+
+	return menu, nil
+}
+
+// ratings returns a copy of the ratings in fname, see ReadRatings
+func (c *Cache) ratings(fname string, opts CSVOptions, layouts []string, strict bool) ([]Rating, error) {
+	v, err := c.load("ratings:"+fname, fname, func() (interface{}, error) {
+		return ReadRatings(fname, opts, layouts, strict)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]Rating(nil), v.([]Rating)...), nil
+}
+
+// combined returns a copy of the ratings in fname, see ReadCombinedRatings
+func (c *Cache) combined(fname string, opts CSVOptions, layouts []string, strict bool) (map[string][]Rating, error) {
+	v, err := c.load("combined:"+fname, fname, func() (interface{}, error) {
+		return ReadCombinedRatings(fname, opts, layouts, strict)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res := map[string][]Rating{}
+	for who, ratings := range v.(map[string][]Rating) {
+		res[who] = append([]Rating(nil), ratings...)
+	}
+
+	return res, nil
+}
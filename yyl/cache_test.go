@@ -0,0 +1,58 @@
+package yyl
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCacheInvalidatesOnModTime(t *testing.T) {
+	fname := writeFile(t, "menu.csv", "number,name\n1,A\n")
+
+	c := NewCache()
+
+	read := func() string {
+		t.Helper()
+
+		menu, err := c.menu(fname, testOpts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(menu) != 1 {
+			t.Fatalf("want 1 item, got %v", menu)
+		}
+
+		return menu[0].Name
+	}
+
+	mtime := time.Date(2015, time.January, 6, 0, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(fname, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := read(); got != "A" {
+		t.Fatalf("want A, got %v", got)
+	}
+
+	// same size and mtime so the cached menu is used
+	if err := os.WriteFile(fname, []byte("number,name\n1,B\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(fname, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := read(); got != "A" {
+		t.Errorf("want cached A, got %v", got)
+	}
+
+	// only the mtime changes since the size is the same
+	mtime = mtime.Add(time.Hour)
+	if err := os.Chtimes(fname, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := read(); got != "B" {
+		t.Errorf("want B after the mtime changed, got %v", got)
+	}
+}
@@ -0,0 +1,700 @@
+package yyl
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CSVOptions control how the menu and ratings files are parsed
+type CSVOptions struct {
+	// Comma is the field delimiter
+	Comma rune
+	// Comment, if non-zero, starts lines that are ignored, including before
+	// the header
+	Comment rune
+	// NoHeader disables skipping the header row
+	NoHeader bool
+}
+
+// cleanRecord trims whitespace from every field in place and replaces invalid
+// UTF-8, such as from latin-1 files, so the report is always valid UTF-8
+func cleanRecord(record []string) {
+	for i := range record {
+		record[i] = strings.ToValidUTF8(strings.TrimSpace(record[i]), "\ufffd")
+	}
+}
+
+// isHeader returns true if the first record of a file is a header that should
+// be skipped. Since every file starts with a numeric column, the record is only
+// considered a header if its first field is not a number.
+func (o CSVOptions) isHeader(record []string) bool {
+	if o.NoHeader || len(record) == 0 {
+		return false
+	}
+
+	_, err := strconv.Atoi(record[0])
+	return err != nil
+}
+
+// newReader returns a csv.Reader for r configured with the options. It strips
+// the UTF-8 byte order mark that some spreadsheets add to the start of files
+// so that it doesn't hide a comment or header on the first line.
+func (o CSVOptions) newReader(r io.Reader) *csv.Reader {
+	br := bufio.NewReader(r)
+	if bom, err := br.Peek(3); err == nil && string(bom) == "\ufeff" {
+		br.Discard(len(bom))
+	}
+
+	res := csv.NewReader(br)
+	res.Comma = o.Comma
+	res.Comment = o.Comment
+
+	return res
+}
+
+// ReadMenu from file, with the item number, name, and an optional category in
+// each record
+func ReadMenu(fname string, opts CSVOptions) ([]MenuItem, error) {
+	var menu []MenuItem
+
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := opts.newReader(f)
+	// the category is optional so check the number of fields ourselves
+	r.FieldsPerRecord = -1
+
+	for first := true; ; first = false {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		cleanRecord(record)
+
+		if first && opts.isHeader(record) {
+			continue
+		}
+
+		if len(record) != 2 && len(record) != 3 {
+			return nil, fmt.Errorf("invalid record in %v", fname)
+		}
+
+		i, err := strconv.Atoi(record[0])
+		if err != nil {
+			return nil, err
+		}
+
+		item := MenuItem{
+			Number:  i,
+			Name:    record[1],
+			Ratings: map[string]Rating{},
+		}
+		if len(record) == 3 {
+			item.Category = record[2]
+		}
+
+		menu = append(menu, item)
+	}
+
+	return menu, nil
+}
+
+// MenuFiles expands menu, a comma-separated list of files or glob patterns,
+// into the file names in order. Patterns that match nothing are kept as is so
+// that reading them reports the missing file. Files that are listed or matched
+// more than once are only returned the first time.
+func MenuFiles(menu string) ([]string, error) {
+	var res []string
+	seen := map[string]bool{}
+
+	for _, v := range strings.Split(menu, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+
+		matches, err := filepath.Glob(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid menu pattern %q: %v", v, err)
+		}
+		if len(matches) == 0 {
+			matches = []string{v}
+		}
+
+		for _, fname := range matches {
+			fname = filepath.Clean(fname)
+			if !seen[fname] {
+				seen[fname] = true
+				res = append(res, fname)
+			}
+		}
+	}
+
+	if len(res) == 0 {
+		return nil, fmt.Errorf("no menu files")
+	}
+
+	return res, nil
+}
+
+// CheckMenu looks for duplicate numbers and gaps in the numbers from 1 to the
+// largest number
+func CheckMenu(menu []MenuItem) []string {
+	var problems []string
+
+	seen := map[int]bool{}
+	max := 0
+
+	for _, item := range menu {
+		if seen[item.Number] {
+			problems = append(problems, fmt.Sprintf("duplicate item number %v", item.Number))
+		}
+		seen[item.Number] = true
+
+		if item.Number > max {
+			max = item.Number
+		}
+	}
+
+	for i := 1; i <= max; i++ {
+		if !seen[i] {
+			problems = append(problems, fmt.Sprintf("missing item number %v", i))
+		}
+	}
+
+	return problems
+}
+
+// ReadNames from file, mapping ratings file names, without the extension, to
+// display names. Unlike the menu and ratings, the header is always skipped
+// unless NoHeader is set.
+func ReadNames(fname string, opts CSVOptions) (map[string]string, error) {
+	names := map[string]string{}
+
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := opts.newReader(f)
+
+	for first := true; ; first = false {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		cleanRecord(record)
+
+		if first && !opts.NoHeader {
+			continue
+		}
+
+		if len(record) != 2 {
+			return nil, fmt.Errorf("invalid record in %v", fname)
+		}
+
+		names[record[0]] = record[1]
+	}
+
+	return names, nil
+}
+
+// displayName for the ratings file stem, using names if it has a mapping.
+// Otherwise, underscores and dashes are replaced with spaces and each word is
+// title-cased.
+func displayName(stem string, names map[string]string) string {
+	if v, ok := names[stem]; ok {
+		return v
+	}
+
+	v := strings.NewReplacer("_", " ", "-", " ").Replace(stem)
+
+	return strings.Title(strings.ToLower(v))
+}
+
+// ReadScaleLabels from file, mapping rating values to display labels such as
+// "great". An optional third field limits the label to ratings out of that
+// max. Like the menu, the header is only skipped if the first field is not a
+// number.
+func ReadScaleLabels(fname string, opts CSVOptions) (ScaleLabels, error) {
+	labels := ScaleLabels{}
+
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := opts.newReader(f)
+	// the max is optional so check the number of fields ourselves
+	r.FieldsPerRecord = -1
+
+	for first := true; ; first = false {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		cleanRecord(record)
+
+		if first && opts.isHeader(record) {
+			continue
+		}
+
+		if len(record) != 2 && len(record) != 3 {
+			return nil, fmt.Errorf("invalid record in %v", fname)
+		}
+
+		v, err := strconv.ParseFloat(record[0], 32)
+		if err != nil {
+			return nil, err
+		}
+
+		var max float64
+		if len(record) == 3 && record[2] != "" {
+			max, err = strconv.ParseFloat(record[2], 32)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if labels[float32(max)] == nil {
+			labels[float32(max)] = map[float32]string{}
+		}
+		labels[float32(max)][float32(v)] = record[1]
+	}
+
+	return labels, nil
+}
+
+// DateLayouts are tried in order when no date format is specified
+var DateLayouts = []string{
+	"20060102",
+	"2006-01-02",
+	"01/02/2006",
+	"2006/01/02",
+}
+
+// CheckLayout makes sure that layout can round trip a known date
+func CheckLayout(layout string) error {
+	want := time.Date(2015, time.January, 6, 0, 0, 0, 0, time.UTC)
+
+	got, err := time.Parse(layout, want.Format(layout))
+	if err != nil {
+		return err
+	}
+
+	if !got.Equal(want) {
+		return fmt.Errorf("layout does not include year, month, and day")
+	}
+
+	return nil
+}
+
+// dateParser parses dates using the first layout that succeeds. Once a layout
+// succeeds, it is preferred for subsequent dates so that a file is parsed
+// consistently.
+type dateParser struct {
+	layouts []string
+	layout  string
+}
+
+// Parse v, trying the previously successful layout first
+func (p *dateParser) Parse(v string) (time.Time, error) {
+	if p.layout != "" {
+		if t, err := time.Parse(p.layout, v); err == nil {
+			return t, nil
+		}
+	}
+
+	for _, layout := range p.layouts {
+		if t, err := time.Parse(layout, v); err == nil {
+			p.layout = layout
+			return t, nil
+		}
+	}
+
+	if len(p.layouts) == 1 {
+		// return the underlying error which is more helpful
+		_, err := time.Parse(p.layouts[0], v)
+		return time.Time{}, err
+	}
+
+	return time.Time{}, fmt.Errorf("unable to parse date %q", v)
+}
+
+// parseRating parses a single record from a ratings file, which has optional
+// fifth and sixth columns for notes and price
+func parseRating(record []string, dates *dateParser) (Rating, error) {
+	r := Rating{}
+
+	if len(record) < 4 || len(record) > 6 {
+		return r, fmt.Errorf("expected 4 to 6 fields, got %v", len(record))
+	}
+
+	var err error
+
+	r.Number, err = strconv.Atoi(record[0])
+	if err != nil {
+		return r, err
+	}
+
+	if record[1] != "" {
+		r.Date, err = dates.Parse(record[1])
+		if err != nil {
+			return r, err
+		}
+		r.FormattedDate = r.Date.Format("Mon Jan 2 2006")
+	}
+
+	// an empty or -1 rating means the item was deliberately skipped
+	if record[2] == "" {
+		r.Skipped = true
+	} else {
+		tf, err := strconv.ParseFloat(record[2], 32)
+		if err != nil {
+			return r, err
+		}
+		r.Value = float32(tf)
+
+		if math.IsNaN(tf) || math.IsInf(tf, 0) {
+			return r, fmt.Errorf("invalid rating %q", record[2])
+		}
+
+		if r.Value == -1 {
+			r.Value = 0
+			r.Skipped = true
+		}
+	}
+
+	tf, err := strconv.ParseFloat(record[3], 32)
+	if err != nil {
+		return r, err
+	}
+	r.Max = float32(tf)
+
+	// the max sizes the rating histogram so it must be a usable number
+	if math.IsNaN(tf) || math.IsInf(tf, 0) || r.Max <= 0 {
+		return r, fmt.Errorf("invalid max %q", record[3])
+	}
+
+	if len(record) > 4 {
+		r.Note = record[4]
+	}
+
+	if len(record) > 5 && record[5] != "" {
+		tf, err = strconv.ParseFloat(record[5], 32)
+		if err != nil {
+			return r, err
+		}
+		r.Price = float32(tf)
+		r.HasPrice = true
+
+		if math.IsNaN(tf) || math.IsInf(tf, 0) || r.Price < 0 {
+			return r, fmt.Errorf("invalid price %q", record[5])
+		}
+	}
+
+	return r, nil
+}
+
+// ReadRatings from file, parsing dates with the first of layouts that works.
+// Malformed records are logged and skipped unless strict is set, in which case
+// they are returned as an error.
+func ReadRatings(fname string, opts CSVOptions, layouts []string, strict bool) ([]Rating, error) {
+	var ratings []Rating
+
+	err := readRatings(fname, opts, layouts, strict, false, func(_ string, rating Rating) {
+		ratings = append(ratings, rating)
+	})
+
+	return ratings, err
+}
+
+// ReadCombinedRatings from a single file with everyone's ratings, which has an
+// extra leading column with the name of the person who made the rating.
+// Returns the ratings grouped by that name, otherwise the same as ReadRatings.
+func ReadCombinedRatings(fname string, opts CSVOptions, layouts []string, strict bool) (map[string][]Rating, error) {
+	ratings := map[string][]Rating{}
+
+	err := readRatings(fname, opts, layouts, strict, true, func(who string, rating Rating) {
+		ratings[who] = append(ratings[who], rating)
+	})
+
+	return ratings, err
+}
+
+// readRatings calls fn for each rating in fname. If combined is set, the first
+// column is the name of the person who made the rating and is passed to fn.
+func readRatings(fname string, opts CSVOptions, layouts []string, strict, combined bool, fn func(string, Rating)) error {
+	dates := &dateParser{layouts: layouts}
+
+	f, err := os.Open(fname)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := opts.newReader(f)
+	// check the number of fields ourselves in parseRating
+	r.FieldsPerRecord = -1
+
+	for first := true; ; first = false {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+
+		var who string
+		if err == nil {
+			cleanRecord(record)
+
+			if combined && len(record) > 0 {
+				who, record = record[0], record[1:]
+			}
+
+			if first && opts.isHeader(record) {
+				continue
+			}
+		}
+		if err, ok := err.(*csv.ParseError); ok && !strict {
+			slog.Warn("skipping malformed record", "file", fname, "line", err.Line, "err", err.Err)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		line, _ := r.FieldPos(0)
+
+		rating, err := parseRating(record, dates)
+		if err == nil && combined && who == "" {
+			err = fmt.Errorf("missing who")
+		}
+		if err != nil {
+			if strict {
+				return fmt.Errorf("invalid record %v:%v: %v", fname, line, err)
+			}
+
+			slog.Warn("skipping invalid record", "file", fname, "line", line, "err", err)
+			continue
+		}
+
+		if !rating.Skipped && (rating.Value < 0 || rating.Value > rating.Max) {
+			err := fmt.Errorf("rating %v outside of [0, %v]", rating.Value, rating.Max)
+			if strict {
+				return fmt.Errorf("invalid record %v:%v: %v", fname, line, err)
+			}
+
+			slog.Warn("clamping rating", "file", fname, "line", line, "err", err)
+
+			if rating.Value < 0 {
+				rating.Value = 0
+			} else {
+				rating.Value = rating.Max
+			}
+		}
+
+		fn(who, rating)
+	}
+
+	return nil
+}
+
+// dedupeRatings removes ratings for the same menu item, keeping either the
+// first or last occurrence. Returns the numbers of the duplicated items, once
+// per extra occurrence.
+func dedupeRatings(ratings []Rating, keepLast bool) ([]Rating, []int) {
+	var res []Rating
+	var dups []int
+
+	// index of the kept rating for each number
+	seen := map[int]int{}
+
+	for _, rating := range ratings {
+		i, ok := seen[rating.Number]
+		if !ok {
+			seen[rating.Number] = len(res)
+			res = append(res, rating)
+			continue
+		}
+
+		dups = append(dups, rating.Number)
+
+		if keepLast {
+			// remove the previous rating and append this one
+			res = append(res[:i], res[i+1:]...)
+			for k, v := range seen {
+				if v > i {
+					seen[k] = v - 1
+				}
+			}
+
+			seen[rating.Number] = len(res)
+			res = append(res, rating)
+		}
+	}
+
+	return res, dups
+}
+
+// outOfOrder counts the dated ratings that are dated before the previous dated
+// rating, must be called before sorting
+func outOfOrder(ratings []Rating) int {
+	var res int
+	var prev time.Time
+
+	for _, rating := range ratings {
+		if rating.Date.IsZero() {
+			continue
+		}
+
+		if rating.Date.Before(prev) {
+			res += 1
+		}
+		prev = rating.Date
+	}
+
+	return res
+}
+
+// sortRatings by date, with undated ratings last in their original order
+func sortRatings(ratings []Rating) {
+	sort.SliceStable(ratings, func(i, j int) bool {
+		a, b := ratings[i].Date, ratings[j].Date
+		if a.IsZero() || b.IsZero() {
+			return !a.IsZero() && b.IsZero()
+		}
+
+		return a.Before(b)
+	})
+}
+
+// relativeDates sets RelativeDate on each dated rating to the time since the
+// previous visit. Ratings must be sorted by date. The first visit and undated
+// ratings are left blank.
+func relativeDates(ratings []Rating) {
+	var prev time.Time
+
+	for i := range ratings {
+		date := ratings[i].Date
+		if date.IsZero() || ratings[i].Skipped {
+			continue
+		}
+
+		if !prev.IsZero() {
+			switch days := int(date.Sub(prev).Hours()/24 + 0.5); days {
+			case 0:
+				ratings[i].RelativeDate = "same day"
+			case 1:
+				ratings[i].RelativeDate = "1 day later"
+			default:
+				ratings[i].RelativeDate = fmt.Sprintf("%v days later", days)
+			}
+		}
+
+		prev = date
+	}
+}
+
+// RatingsFiles returns the csv files in dir, skipping directories, hidden
+// files, and anything else that isn't a regular csv file
+func RatingsFiles(dir string) ([]os.FileInfo, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []os.FileInfo
+	for _, fi := range files {
+		if !fi.Mode().IsRegular() || strings.HasPrefix(fi.Name(), ".") || filepath.Ext(fi.Name()) != ".csv" {
+			continue
+		}
+
+		res = append(res, fi)
+	}
+
+	return res, nil
+}
+
+// imagePath returns the path to the image for menu item n in dir
+func imagePath(dir string, n int) string {
+	return filepath.Join(dir, fmt.Sprintf("%02d.jpg", n))
+}
+
+// Src returns the src for the item's image, reading and encoding the image if
+// it is embedded. The template needs the whole data URI as a single value so
+// the encoded image is held in memory, but only until it is written, so that
+// rendering holds one image at a time rather than all of them.
+func (m MenuItem) Src() (template.URL, error) {
+	if !m.Embed {
+		return m.Image, nil
+	}
+
+	fname := filepath.FromSlash(string(m.Image))
+
+	fi, err := os.Stat(fname)
+	if err != nil {
+		return "", err
+	}
+
+	buf := &strings.Builder{}
+	buf.Grow(len(dataURIPrefix) + base64.StdEncoding.EncodedLen(int(fi.Size())))
+
+	if err := embedImage(buf, fname); err != nil {
+		return "", err
+	}
+
+	return template.URL(buf.String()), nil
+}
+
+const dataURIPrefix = "data:image/jpeg;base64,"
+
+// embedImage writes the image at fname to w as a data URI, encoding it in
+// chunks as it is read
+func embedImage(w io.Writer, fname string) error {
+	f, err := os.Open(fname)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := io.WriteString(w, dataURIPrefix); err != nil {
+		return err
+	}
+
+	enc := base64.NewEncoder(base64.StdEncoding, w)
+	if _, err := io.Copy(enc, f); err != nil {
+		return err
+	}
+
+	return enc.Close()
+}
@@ -0,0 +1,256 @@
+package yyl
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFile writes contents to name in a temporary directory and returns the
+// path
+func writeFile(t testing.TB, name, contents string) string {
+	t.Helper()
+
+	fname := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(fname, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return fname
+}
+
+var testOpts = CSVOptions{Comma: ','}
+
+func TestReadRatingsInvalidNumbers(t *testing.T) {
+	for _, row := range []string{
+		"1,20150106,NaN,10",
+		"1,20150106,Inf,10",
+		"1,20150106,5,NaN",
+		"1,20150106,5,Inf",
+		"1,20150106,0,0",
+		"1,20150106,0,-1",
+		"1,20150106,5,10,,NaN",
+		"1,20150106,5,10,,Inf",
+		"1,20150106,5,10,,-1",
+	} {
+		fname := writeFile(t, "bob.csv", "number,date,rating,max\n"+row+"\n2,20150107,7,10\n")
+
+		ratings, err := ReadRatings(fname, testOpts, DateLayouts, false)
+		if err != nil {
+			t.Errorf("%v: %v", row, err)
+			continue
+		}
+		if len(ratings) != 1 || ratings[0].Number != 2 {
+			t.Errorf("%v: want only item 2, got %v", row, ratings)
+		}
+
+		// used to panic building the rating histogram
+		ComputeStats("bob", ratings, nil, false, 1)
+
+		if _, err := ReadRatings(fname, testOpts, DateLayouts, true); err == nil {
+			t.Errorf("%v: want error in strict mode", row)
+		}
+	}
+}
+
+func TestReadRatingsClamp(t *testing.T) {
+	buf := &bytes.Buffer{}
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(buf, nil)))
+	defer slog.SetDefault(prev)
+
+	for _, c := range []struct {
+		row  string
+		want float32
+	}{
+		{"1,20150106,11,10", 10},
+		{"1,20150106,-0.5,10", 0},
+	} {
+		buf.Reset()
+
+		fname := writeFile(t, "bob.csv", "number,date,rating,max\n"+c.row+"\n")
+
+		ratings, err := ReadRatings(fname, testOpts, DateLayouts, false)
+		if err != nil {
+			t.Errorf("%v: %v", c.row, err)
+			continue
+		}
+		if len(ratings) != 1 || ratings[0].Value != c.want {
+			t.Errorf("%v: want value clamped to %v, got %v", c.row, c.want, ratings)
+		}
+		if !strings.Contains(buf.String(), "clamping rating") {
+			t.Errorf("%v: want warning, got %q", c.row, buf.String())
+		}
+
+		// used to panic indexing past the end of the rating histogram
+		s := ComputeStats("bob", ratings, testMenu(1), false, 1)
+		if s.Count != 1 {
+			t.Errorf("%v: want 1 rating, got %v", c.row, s.Count)
+		}
+
+		if _, err := ReadRatings(fname, testOpts, DateLayouts, true); err == nil {
+			t.Errorf("%v: want error in strict mode", c.row)
+		}
+	}
+}
+
+func TestReadMenuOptionalCategory(t *testing.T) {
+	fname := writeFile(t, "menu.csv", "number,name,category\n1,A,Soup\n2,B,\n3,C\n")
+
+	menu, err := ReadMenu(fname, testOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(menu) != 3 {
+		t.Fatalf("want 3 items, got %v", len(menu))
+	}
+	if menu[0].Category != "Soup" || menu[2].Name != "C" || menu[2].Category != "" {
+		t.Errorf("unexpected menu: %v", menu)
+	}
+}
+
+func TestReadScaleLabelsOptionalMax(t *testing.T) {
+	fname := writeFile(t, "labels.csv", "value,label,max\n1,meh\n5,great,5\n10,perfect,10\n")
+
+	labels, err := ReadScaleLabels(fname, testOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		value, max float32
+		want       string
+	}{
+		{1, 10, "meh"},
+		{5, 5, "great"},
+		{10, 10, "perfect"},
+	} {
+		if got, _ := labels.Label(c.value, c.max); got != c.want {
+			t.Errorf("Label(%v, %v) = %q, want %q", c.value, c.max, got, c.want)
+		}
+	}
+}
+
+func TestReadBOM(t *testing.T) {
+	opts := CSVOptions{Comma: ',', Comment: '#'}
+
+	fname := writeFile(t, "menu.csv", "\ufeff# exported from a spreadsheet\nnumber,name\n1,A\n")
+
+	menu, err := ReadMenu(fname, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(menu) != 1 || menu[0].Number != 1 || menu[0].Name != "A" {
+		t.Errorf("unexpected menu: %v", menu)
+	}
+
+	fname = writeFile(t, "bob.csv", "\ufeff1,20150106,7,10\n")
+
+	ratings, err := ReadRatings(fname, opts, DateLayouts, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ratings) != 1 || ratings[0].Number != 1 {
+		t.Errorf("unexpected ratings: %v", ratings)
+	}
+}
+
+func TestReadMenuNoHeader(t *testing.T) {
+	fname := writeFile(t, "menu.csv", "1,A\n2,B\n")
+
+	for _, opts := range []CSVOptions{
+		{Comma: ','},
+		{Comma: ',', NoHeader: true},
+	} {
+		menu, err := ReadMenu(fname, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(menu) != 2 || menu[0].Number != 1 || menu[0].Name != "A" {
+			t.Errorf("NoHeader=%v: want items 1 and 2, got %v", opts.NoHeader, menu)
+		}
+	}
+}
+
+func TestRatingsFilesMixedDir(t *testing.T) {
+	dir := writeDir(t, map[string]string{
+		"menu.csv":              "number,name\n1,A\n",
+		"ratings/bob.csv":       "number,date,rating,max\n1,20150106,7,10\n",
+		"ratings/.DS_Store":     "\x00\x00\x00\x01Bud1",
+		"ratings/.hidden.csv":   "not,a,ratings,file\n",
+		"ratings/README":        "one file per person\n",
+		"ratings/old/alice.csv": "number,date,rating,max\n1,20150106,5,10\n",
+		"ratings/notes.csv.bak": "garbage\n",
+	})
+
+	files, err := RatingsFiles(filepath.Join(dir, "ratings"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 1 || files[0].Name() != "bob.csv" {
+		var names []string
+		for _, fi := range files {
+			names = append(names, fi.Name())
+		}
+		t.Errorf("want only bob.csv, got %v", names)
+	}
+
+	// strict so that reading any of the other files would fail
+	cfg := testConfig(dir)
+	cfg.Strict = true
+
+	report, err := BuildReport(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if people := report.People(); len(people) != 1 || people[0] != "Bob" {
+		t.Errorf("want only Bob, got %v", people)
+	}
+}
+
+func TestMenuFilesDuplicates(t *testing.T) {
+	dir := writeDir(t, map[string]string{
+		"menu.csv":        "number,name\n1,A\n",
+		"menu-extra.csv":  "number,name\n2,B\n",
+		"ratings/bob.csv": "number,date,rating,max\n1,20150106,7,10\n",
+	})
+
+	menu := filepath.Join(dir, "menu.csv")
+	extra := filepath.Join(dir, "menu-extra.csv")
+
+	for _, patterns := range []string{
+		menu + "," + menu,
+		menu + "," + dir + string(filepath.Separator) + "." + string(filepath.Separator) + "menu.csv",
+		menu + "," + filepath.Join(dir, "menu*.csv"),
+	} {
+		files, err := MenuFiles(patterns)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := []string{menu}
+		if strings.Contains(patterns, "*") {
+			want = append(want, extra)
+		}
+		if strings.Join(files, ",") != strings.Join(want, ",") {
+			t.Errorf("%v: want %v, got %v", patterns, want, files)
+		}
+
+		cfg := testConfig(dir)
+		cfg.Menu = patterns
+
+		report, err := BuildReport(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s := report.Stats["Bob"]; s.Total != len(want) {
+			t.Errorf("%v: want %v items, got %v", patterns, len(want), s.Total)
+		}
+	}
+}
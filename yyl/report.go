@@ -0,0 +1,570 @@
+package yyl
+
+import (
+	"fmt"
+	"html/template"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config contains the settings for building a report
+type Config struct {
+	Title string
+	Intro string
+
+	// Menu, Ratings, and Img are paths to the menu file, ratings directory,
+	// and images directory. Menu may be a comma-separated list of files or
+	// glob patterns, see MenuFiles. Names is the optional display names file
+	// and ScaleLabels the optional file of labels for rating values.
+	Menu        string
+	Ratings     string
+	Names       string
+	ScaleLabels string
+	Img         string
+
+	// Opts and Layouts are used to parse the files and dates
+	Opts    CSVOptions
+	Layouts []string
+
+	// Combined is set if Ratings is a single file with everyone's ratings
+	// rather than a directory with a file per person
+	Combined bool
+
+	EmbedImages bool
+	CheckMenu   bool
+	Strict      bool
+	KeepLast    bool
+	// AllowRepeats treats duplicate ratings as revisits rather than mistakes,
+	// keeping the latest and counting the rest
+	AllowRepeats bool
+	ByYear       bool
+	SameDay      bool
+	// Bucket is the width of the buckets in the rating histogram
+	Bucket    float32
+	WeekStart time.Weekday
+
+	Top      int
+	Charts   string
+	ChartSet []string
+	Theme    Theme
+	Mode     string
+	Collapse bool
+	Currency string
+	// Cache, if set, is used to skip re-reading unchanged files
+	Cache *Cache
+
+	// From and To limit the ratings to those dated within the range, both
+	// inclusive. Either may be zero to leave that end open. Undated ratings
+	// are kept if KeepUndated is set.
+	From        time.Time
+	To          time.Time
+	KeepUndated bool
+
+	// Exclude skips people by file stem or display name, ignoring case
+	Exclude []string
+	// Anonymize replaces names with "Reviewer A", "Reviewer B", etc.
+	Anonymize bool
+
+	// Now is when the report was generated, defaults to the current time.
+	// TimeFormat is the layout used to display it.
+	Now        time.Time
+	TimeFormat string
+
+	// Stamp, if set, stamps Version and the generation time into the page
+	Stamp   bool
+	Version string
+}
+
+// ratingsSource is where a person's ratings come from, stem is used for their
+// display name and load returns their ratings
+type ratingsSource struct {
+	fname string
+	stem  string
+	load  func() ([]Rating, error)
+}
+
+// ratingsSources returns a source per person, either a file per person in the
+// ratings directory or a group per person from the combined file
+func ratingsSources(cfg Config) ([]ratingsSource, error) {
+	var res []ratingsSource
+
+	if cfg.Combined {
+		combined, err := cfg.Cache.combined(cfg.Ratings, cfg.Opts, cfg.Layouts, cfg.Strict)
+		if err != nil {
+			return nil, err
+		}
+
+		var whos []string
+		for who := range combined {
+			whos = append(whos, who)
+		}
+		sort.Strings(whos)
+
+		for _, who := range whos {
+			ratings := combined[who]
+			res = append(res, ratingsSource{
+				fname: cfg.Ratings,
+				stem:  who,
+				load:  func() ([]Rating, error) { return ratings, nil },
+			})
+		}
+
+		return res, nil
+	}
+
+	files, err := RatingsFiles(cfg.Ratings)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, fi := range files {
+		fname := filepath.Join(cfg.Ratings, fi.Name())
+		res = append(res, ratingsSource{
+			fname: fname,
+			stem:  strings.TrimSuffix(fi.Name(), ".csv"),
+			load: func() ([]Rating, error) {
+				return cfg.Cache.ratings(fname, cfg.Opts, cfg.Layouts, cfg.Strict)
+			},
+		})
+	}
+
+	return res, nil
+}
+
+// personResult is the result of processing a person's ratings file
+type personResult struct {
+	fname string
+	who   string
+
+	ratings []Rating
+	dups    []int
+	repeats []int
+	stats   Stats
+	years   []YearStats
+
+	err error
+}
+
+// filterDates returns the ratings dated between from and to, inclusive, and
+// the undated ratings if keepUndated is set. Zero from or to are unbounded.
+func filterDates(ratings []Rating, from, to time.Time, keepUndated bool) []Rating {
+	var res []Rating
+
+	for _, rating := range ratings {
+		switch {
+		case rating.Date.IsZero():
+			if !keepUndated {
+				continue
+			}
+		case !from.IsZero() && rating.Date.Before(from):
+			continue
+		case !to.IsZero() && rating.Date.After(to):
+			continue
+		}
+
+		res = append(res, rating)
+	}
+
+	return res
+}
+
+// processPerson loads the ratings from src and computes the stats for that
+// person. Does not modify menu so it is safe to call concurrently.
+func processPerson(src ratingsSource, menu []MenuItem, names map[string]string, labels ScaleLabels, cfg Config) personResult {
+	who := displayName(src.stem, names)
+
+	res := personResult{
+		fname: src.fname,
+		who:   who,
+	}
+
+	ratings, err := src.load()
+	if err != nil {
+		res.err = err
+		return res
+	}
+
+	if !cfg.From.IsZero() || !cfg.To.IsZero() {
+		ratings = filterDates(ratings, cfg.From, cfg.To, cfg.KeepUndated)
+	}
+
+	var unordered int
+	if cfg.AllowRepeats {
+		// repeats are revisits, keep the latest for display
+		unordered = outOfOrder(ratings)
+		sortRatings(ratings)
+		ratings, res.repeats = dedupeRatings(ratings, true)
+	} else {
+		ratings, res.dups = dedupeRatings(ratings, cfg.KeepLast)
+		unordered = outOfOrder(ratings)
+		sortRatings(ratings)
+	}
+	relativeDates(ratings)
+
+	for i := range ratings {
+		if !ratings[i].Skipped {
+			ratings[i].Label, _ = labels.Label(ratings[i].Value, ratings[i].Max)
+		}
+	}
+
+	res.ratings = ratings
+	res.stats = ComputeStats(who, ratings, menu, cfg.SameDay, cfg.Bucket)
+	res.stats.OutOfOrder = unordered
+	res.stats.Repeats = len(res.repeats)
+	res.stats.MostRepeated, res.stats.MostRepeatedVisits = mostRepeated(res.repeats, menu)
+	res.stats.rotateWeekdays(cfg.WeekStart)
+	res.stats.ScaleLabels = labels
+
+	if cfg.ByYear {
+		for _, year := range groupByYear(ratings) {
+			s := ComputeStats(who, year, menu, cfg.SameDay, cfg.Bucket)
+			s.rotateWeekdays(cfg.WeekStart)
+			s.ScaleLabels = labels
+
+			res.years = append(res.years, YearStats{
+				Year:  year[0].Date.Year(),
+				Stats: s,
+			})
+		}
+	}
+
+	return res
+}
+
+// excluded checks whether the person with the given file stem and display
+// name should be skipped
+func excluded(stem, who string, exclude []string) bool {
+	for _, v := range exclude {
+		if strings.EqualFold(v, stem) || strings.EqualFold(v, who) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// anonymize returns a stable alias for each person, assigned in name order
+func anonymize(results []personResult) map[string]string {
+	res := map[string]string{}
+
+	var whos []string
+	for _, r := range results {
+		if _, ok := res[r.who]; !ok {
+			res[r.who] = ""
+			whos = append(whos, r.who)
+		}
+	}
+	sort.Strings(whos)
+
+	for i, who := range whos {
+		res[who] = "Reviewer " + reviewerLetters(i)
+	}
+
+	return res
+}
+
+// reviewerLetters returns A, B, ..., Z, AA, AB, ... for i = 0, 1, ...
+func reviewerLetters(i int) string {
+	var res []byte
+	for i++; i > 0; i = (i - 1) / 26 {
+		res = append([]byte{byte('A' + (i-1)%26)}, res...)
+	}
+
+	return string(res)
+}
+
+// BuildReport reads the menu and ratings and computes all the stats
+func BuildReport(cfg Config) (Report, error) {
+	fnames, err := MenuFiles(cfg.Menu)
+	if err != nil {
+		return Report{}, err
+	}
+
+	var menu []MenuItem
+	// file that each item number first appears in, to find collisions
+	// between files
+	source := map[int]string{}
+
+	for _, fname := range fnames {
+		items, err := cfg.Cache.menu(fname, cfg.Opts)
+		if err != nil {
+			return Report{}, err
+		}
+
+		for _, item := range items {
+			prev, ok := source[item.Number]
+			if !ok {
+				source[item.Number] = fname
+				continue
+			}
+			if prev == fname {
+				continue
+			}
+
+			if cfg.Strict {
+				return Report{}, fmt.Errorf("item number %v is in both %v and %v", item.Number, prev, fname)
+			}
+
+			slog.Warn("item number in multiple menus", "item", item.Number, "file", fname, "other", prev)
+		}
+
+		menu = append(menu, items...)
+	}
+
+	if cfg.CheckMenu {
+		for _, problem := range CheckMenu(menu) {
+			if cfg.Strict {
+				return Report{}, fmt.Errorf("invalid menu %v: %v", cfg.Menu, problem)
+			}
+
+			slog.Warn("menu problem", "file", cfg.Menu, "problem", problem)
+		}
+	}
+
+	for i := range menu {
+		fname := imagePath(cfg.Img, menu[i].Number)
+
+		if _, err := os.Stat(fname); err != nil {
+			if !os.IsNotExist(err) {
+				slog.Warn("unable to find image", "item", menu[i].Number, "err", err)
+			}
+			continue
+		}
+
+		// embedded images are encoded while rendering, one at a time, so
+		// that the report doesn't hold every image in memory
+		menu[i].Image = template.URL(filepath.ToSlash(fname))
+		menu[i].HasImage = true
+		menu[i].Embed = cfg.EmbedImages
+	}
+
+	sources, err := ratingsSources(cfg)
+	if err != nil {
+		return Report{}, err
+	}
+
+	var names map[string]string
+	if cfg.Names != "" {
+		names, err = ReadNames(cfg.Names, cfg.Opts)
+		if err != nil {
+			return Report{}, err
+		}
+	}
+
+	var labels ScaleLabels
+	if cfg.ScaleLabels != "" {
+		labels, err = ReadScaleLabels(cfg.ScaleLabels, cfg.Opts)
+		if err != nil {
+			return Report{}, err
+		}
+	}
+
+	if len(cfg.Exclude) > 0 {
+		var kept []ratingsSource
+		for _, src := range sources {
+			if !excluded(src.stem, displayName(src.stem, names), cfg.Exclude) {
+				kept = append(kept, src)
+			}
+		}
+		sources = kept
+	}
+
+	// index of each item number in the menu, the first if there are duplicates
+	index := map[int]int{}
+	for i := range menu {
+		if _, ok := index[menu[i].Number]; !ok {
+			index[menu[i].Number] = i
+		}
+	}
+
+	stats := map[string]Stats{}
+	years := map[string][]YearStats{}
+	// everyone's ratings, for the group stats
+	var all []Rating
+
+	// ratings for unknown menu items, by person
+	orphans := map[string][]int{}
+	// duplicate ratings, by person
+	duplicates := map[string][]int{}
+	// everyone's repeat visits, for the group's most repeated item
+	var repeats []int
+
+	// read the ratings and compute stats for each person in parallel, the
+	// results are combined in order below so that the output does not depend
+	// on scheduling
+	results := make([]personResult, len(sources))
+
+	work := make(chan int)
+	var wg sync.WaitGroup
+
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := range work {
+				results[i] = processPerson(sources[i], menu, names, labels, cfg)
+			}
+		}()
+	}
+
+	for i := range sources {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+
+	keep := "first"
+	if cfg.KeepLast {
+		keep = "last"
+	}
+
+	var aliases map[string]string
+	if cfg.Anonymize {
+		aliases = anonymize(results)
+	}
+
+	for _, res := range results {
+		if res.err != nil {
+			return Report{}, res.err
+		}
+
+		who := res.who
+		if aliases != nil {
+			who = aliases[who]
+		}
+
+		for _, v := range res.dups {
+			slog.Warn("duplicate rating", "item", v, "file", res.fname, "keeping", keep)
+		}
+		if len(res.dups) > 0 {
+			duplicates[who] = res.dups
+		}
+		repeats = append(repeats, res.repeats...)
+
+		// attach ratings to menu items
+		for _, rating := range res.ratings {
+			if i, ok := index[rating.Number]; ok && rating.Skipped {
+				if menu[i].Skipped == nil {
+					menu[i].Skipped = map[string]Rating{}
+				}
+				menu[i].Skipped[who] = rating
+				continue
+			} else if ok {
+				menu[i].Ratings[who] = rating
+				continue
+			}
+
+			if cfg.Strict {
+				return Report{}, fmt.Errorf("unknown menu item %v in %v", rating.Number, res.fname)
+			}
+
+			orphans[who] = append(orphans[who], rating.Number)
+		}
+
+		for _, rating := range res.ratings {
+			if !rating.Skipped {
+				all = append(all, rating)
+			}
+		}
+
+		stats[who] = res.stats
+		if len(res.years) > 0 {
+			years[who] = res.years
+		}
+	}
+
+	for _, who := range sortedKeys(orphans) {
+		slog.Warn("unknown menu items", "who", who, "items", orphans[who])
+	}
+	for _, who := range sortedKeys(duplicates) {
+		slog.Warn("duplicate ratings", "who", who, "count", len(duplicates[who]), "items", duplicates[who])
+	}
+
+	report := Report{
+		Title:    cfg.Title,
+		Intro:    cfg.Intro,
+		Menu:     menu,
+		Stats:    stats,
+		Years:    years,
+		Charts:   cfg.Charts,
+		ChartSet: cfg.ChartSet,
+		Theme:    cfg.Theme,
+		Mode:     cfg.Mode,
+		Collapse: cfg.Collapse,
+		Currency: cfg.Currency,
+
+		Anonymized: cfg.Anonymize,
+
+		Stamp:   cfg.Stamp,
+		Version: cfg.Version,
+	}
+
+	report.Generated = cfg.Now
+	if report.Generated.IsZero() {
+		report.Generated = time.Now()
+	}
+	report.FormattedGenerated = report.Generated.Format(cfg.TimeFormat)
+
+	report.Categories = categories(menu)
+
+	items := computeItemStats(menu)
+
+	ranked := rankItems(items)
+	n := cfg.Top
+	if n > len(ranked) {
+		n = len(ranked)
+	}
+	report.Top = ranked[:n]
+	for i := len(ranked) - 1; i >= len(ranked)-n; i-- {
+		report.Bottom = append(report.Bottom, ranked[i])
+	}
+
+	for _, who := range report.People() {
+		report.Reviewers = append(report.Reviewers, PersonStats{who, stats[who]})
+	}
+
+	report.Group = GroupStats(all, cfg.Bucket)
+	report.Scale = Scale
+
+	report.Favorite, report.LeastFavorite = verdict(items)
+	for _, p := range report.Reviewers {
+		report.GroupCompleted += p.Stats.Completed
+		report.GroupTotal += p.Stats.Total
+	}
+	report.GroupPercent = ratio(report.GroupCompleted, report.GroupTotal)
+
+	report.Finishers, report.Unfinished = finishers(report.Reviewers)
+	report.Adventurous, report.AdventurousCount, report.Cautious, report.CautiousCount = adventurers(report.Reviewers)
+	report.MostRepeated, report.MostRepeatedVisits = mostRepeated(repeats, menu)
+	report.Correlations = correlations(menu, report.People())
+
+	report.BestValue, report.Unpriced = valueItems(items)
+	if len(report.BestValue) > cfg.Top {
+		report.BestValue = report.BestValue[:cfg.Top]
+	}
+
+	report.Controversial = controversialItems(items)
+	if len(report.Controversial) > cfg.Top {
+		report.Controversial = report.Controversial[:cfg.Top]
+	}
+
+	return report, nil
+}
+
+// sortedKeys returns the keys of m in sorted order
+func sortedKeys(m map[string][]int) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,145 @@
+package yyl
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeDir writes each of the files, keyed by slash-separated path, to a
+// temporary directory and returns it
+func writeDir(t testing.TB, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, contents := range files {
+		fname := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fname), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fname, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+// testConfig returns a config for the menu.csv and ratings in dir
+func testConfig(dir string) Config {
+	return Config{
+		Menu:    filepath.Join(dir, "menu.csv"),
+		Ratings: filepath.Join(dir, "ratings"),
+		Img:     filepath.Join(dir, "img"),
+		Opts:    testOpts,
+		Layouts: DateLayouts,
+		Bucket:  1,
+		Now:     time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestBuildReportShuffled(t *testing.T) {
+	dir := writeDir(t, map[string]string{
+		"menu.csv":             "number,name\n1,A\n2,B\n3,C\n4,D\n",
+		"ratings/sorted.csv":   "number,date,rating,max\n1,20150101,5,10\n2,20150103,5,10\n3,20150113,5,10\n4,20150114,5,10\n",
+		"ratings/shuffled.csv": "number,date,rating,max\n3,20150113,5,10\n1,20150101,5,10\n4,20150114,5,10\n2,20150103,5,10\n",
+	})
+
+	report, err := BuildReport(testConfig(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, who := range []string{"Sorted", "Shuffled"} {
+		s := report.Stats[who]
+
+		if want := 10 * 24 * time.Hour; s.Longest != want {
+			t.Errorf("%v: want longest gap %v, got %v", who, want, s.Longest)
+		}
+		if s.LongestAfter != "C" {
+			t.Errorf("%v: want longest gap ending at C, got %q", who, s.LongestAfter)
+		}
+		if s.Shortest != 24*time.Hour {
+			t.Errorf("%v: want shortest gap 24h, got %v", who, s.Shortest)
+		}
+	}
+}
+
+// writeSynthetic writes a menu with items and a ratings file per person,
+// each of whom has rated every item, and returns the directory
+func writeSynthetic(b *testing.B, items, people int) string {
+	b.Helper()
+
+	menu := &strings.Builder{}
+	menu.WriteString("number,name\n")
+	for i := 1; i <= items; i++ {
+		fmt.Fprintf(menu, "%v,Item %v\n", i, i)
+	}
+
+	files := map[string]string{
+		"menu.csv": menu.String(),
+	}
+
+	start := time.Date(2015, time.January, 1, 0, 0, 0, 0, time.UTC)
+	for p := 0; p < people; p++ {
+		ratings := &strings.Builder{}
+		ratings.WriteString("number,date,rating,max\n")
+		for i := 1; i <= items; i++ {
+			date := start.AddDate(0, 0, i*(p+1)%(3*items))
+			fmt.Fprintf(ratings, "%v,%v,%v,10\n", i, date.Format("2006-01-02"), (i+p)%11)
+		}
+
+		files[fmt.Sprintf("ratings/person%03d.csv", p)] = ratings.String()
+	}
+
+	return writeDir(b, files)
+}
+
+func BenchmarkBuildReportLargeMenu(b *testing.B) {
+	cfg := testConfig(writeSynthetic(b, 5000, 10))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := BuildReport(cfg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkBuildReportManyPeople(b *testing.B) {
+	cfg := testConfig(writeSynthetic(b, 100, 200))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := BuildReport(cfg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestBuildReportAdventurousSkipped(t *testing.T) {
+	dir := writeDir(t, map[string]string{
+		"menu.csv":            "number,name\n1,A\n2,B\n3,C\n",
+		"ratings/skipper.csv": "number,date,rating,max\n1,20150106,,10\n2,20150107,,10\n3,20150108,,10\n",
+		"ratings/taster.csv":  "number,date,rating,max\n1,20150106,5,10\n",
+	})
+
+	report, err := BuildReport(testConfig(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Adventurous) != 1 || report.Adventurous[0] != "Taster" || report.AdventurousCount != 1 {
+		t.Errorf("want Taster with 1 item rated, got %v with %v", report.Adventurous, report.AdventurousCount)
+	}
+	if len(report.Cautious) != 1 || report.Cautious[0] != "Skipper" || report.CautiousCount != 0 {
+		t.Errorf("want Skipper with 0 items rated, got %v with %v", report.Cautious, report.CautiousCount)
+	}
+}
@@ -0,0 +1,901 @@
+package yyl
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"time"
+)
+
+// bucketOf returns the index of the histogram bucket for v, allowing for
+// rounding errors in the division
+func bucketOf(v, bucket float32) int {
+	return int(math.Floor(float64(v/bucket) + 1e-6))
+}
+
+// bucketValue returns the lowest rating in bucket i, rounded so that float32
+// noise such as 0.90000004 isn't displayed
+func bucketValue(i int, bucket float32) float32 {
+	return float32(math.Round(float64(i)*float64(bucket)*1e4) / 1e4)
+}
+
+// ratio returns n as a percentage of total, zero if total is zero so that
+// empty charts have empty bars rather than NaN
+func ratio(n, total int) float32 {
+	if total == 0 {
+		return 0
+	}
+
+	return float32(n) / float32(total) * 100
+}
+
+// median of values, averaging the two middle values when there are an even
+// number of values. Sorts values in place.
+func median(values []float32) float32 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+
+	mid := len(values) / 2
+	if len(values)%2 == 0 {
+		return (values[mid-1] + values[mid]) / 2
+	}
+
+	return values[mid]
+}
+
+// mode returns the most frequent rating in the histogram, preferring the lowest
+// value when multiple ratings are equally frequent. Also returns the other
+// ratings that tied.
+func mode(hist []float32) (int, []int) {
+	res := 0
+	var ties []int
+
+	for i := range hist {
+		if hist[i] > hist[res] {
+			res = i
+			ties = nil
+		} else if i != res && hist[i] == hist[res] {
+			ties = append(ties, i)
+		}
+	}
+
+	return res, ties
+}
+
+// ComputeStats for a person from their ratings, which must be sorted by date.
+// The menu is used to name items and find ones that have not been rated. If
+// sameDay is set, same-day visits count towards the shortest gap. The rating
+// histogram has a bucket per bucket points, one if it is not a positive
+// number.
+func ComputeStats(who string, ratings []Rating, menu []MenuItem, sameDay bool, bucket float32) Stats {
+	if bucket <= 0 || math.IsNaN(float64(bucket)) || math.IsInf(float64(bucket), 0) {
+		bucket = 1
+	}
+
+	s := Stats{
+		Weekdays:           make([]int, 7),
+		WeekdayMeanRatings: make([]float32, 7),
+		GapBuckets:         make([]int, len(GapLabels)),
+		Bucket:             bucket,
+	}
+
+	// menu items that have been rated or skipped, skipped items only count
+	// towards completion
+	covered := map[int]bool{}
+
+	var rated []Rating
+	for _, rating := range ratings {
+		if rating.Skipped {
+			covered[rating.Number] = true
+			s.Skipped += 1
+			continue
+		}
+
+		rated = append(rated, rating)
+	}
+	ratings = rated
+
+	// nothing to compute for people who have not rated anything yet
+	if len(ratings) == 0 {
+		s.countCompleted(menu, covered)
+		return s
+	}
+
+	names := map[int]string{}
+	for _, item := range menu {
+		names[item.Number] = item.Name
+	}
+
+	count := 0
+	var total float32
+	var values []float32
+
+	// track the iso year as well so that weeks in different years differ
+	var year, week int
+	weekcount := 0
+	var prev time.Time
+	hasShortest := false
+	inconsistent := false
+	hasTop := false
+	// ratings may have different maxes so compare normalized values
+	var topValue, bottomValue float32
+
+	dated := 0
+	priced := 0
+
+	for _, rating := range ratings {
+		name, ok := names[rating.Number]
+		if ok {
+			// ratings are sorted by date so the earliest wins ties
+			v := rating.Normalized()
+			if !hasTop || v > topValue {
+				s.TopItem = ItemRef{rating.Number, name}
+				s.TopValue, s.TopMax = rating.Value, rating.Max
+				topValue = v
+			}
+			if !hasTop || v < bottomValue {
+				s.BottomItem = ItemRef{rating.Number, name}
+				s.BottomValue, s.BottomMax = rating.Value, rating.Max
+				bottomValue = v
+			}
+
+			hasTop = true
+			covered[rating.Number] = true
+		}
+
+		// number of entries
+		count += 1
+
+		// compute frequency of ratings
+		if s.Ratings == nil {
+			// size from the first max, grown below if others differ
+			s.Ratings = make([]float32, bucketOf(rating.Max, bucket)+1)
+			s.Max = rating.Max
+		} else if rating.Max != s.Max {
+			if !inconsistent {
+				slog.Warn("inconsistent max ratings", "who", who, "max", s.Max, "other", rating.Max)
+				inconsistent = true
+			}
+
+			// grow the histogram to fit the largest max
+			if rating.Max > s.Max {
+				hist := make([]float32, bucketOf(rating.Max, bucket)+1)
+				copy(hist, s.Ratings)
+				s.Ratings = hist
+				s.Max = rating.Max
+			}
+		}
+
+		s.Ratings[bucketOf(rating.Value, bucket)] += 1
+
+		total += rating.Value
+		values = append(values, rating.Value)
+
+		if rating.HasPrice {
+			s.TotalSpent += rating.Price
+			priced += 1
+			s.HasPrice = true
+		}
+
+		// some don't have dates
+		if !rating.Date.IsZero() {
+			// compute frequency plots for day of the week
+			s.Weekdays[rating.Date.Weekday()] += 1
+			s.WeekdayMeanRatings[rating.Date.Weekday()] += rating.Value
+
+			if d := rating.Date.Weekday(); d == time.Saturday || d == time.Sunday {
+				s.WeekendCount += 1
+				s.WeekendMean += rating.Value
+			} else {
+				s.WeekdayCount += 1
+				s.WeekdayMean += rating.Value
+			}
+
+			// compute frequency plots for month of the year
+			s.Months[rating.Date.Month()-1] += 1
+
+			if y, v := rating.Date.ISOWeek(); y == year && v == week {
+				weekcount += 1
+			} else {
+				if weekcount > s.MaxPerWeek {
+					s.MaxPerWeek = weekcount
+				}
+				year, week = y, v
+				weekcount = 1
+			}
+
+			if !prev.IsZero() {
+				v := rating.Date.Sub(prev)
+				s.GapBuckets[gapBucket(int(math.Round(v.Hours()/24)))] += 1
+
+				if v > s.Longest {
+					s.Longest = v
+					s.LongestAfter = name
+				}
+
+				// same-day visits produce zero-length gaps
+				if v > 0 || sameDay {
+					if !hasShortest || v < s.Shortest {
+						s.Shortest = v
+						s.ShortestAfter = name
+						hasShortest = true
+					}
+				}
+			}
+
+			if s.FirstDate.IsZero() || rating.Date.Before(s.FirstDate) {
+				s.FirstDate = rating.Date
+			}
+			if rating.Date.After(s.LastDate) {
+				s.LastDate = rating.Date
+			}
+
+			prev = rating.Date
+			dated += 1
+			s.Dated = append(s.Dated, rating)
+
+			s.HasDate = true
+		}
+	}
+
+	// the last week is never compared in the loop
+	if weekcount > s.MaxPerWeek {
+		s.MaxPerWeek = weekcount
+	}
+
+	// only dated ratings count towards the day of week and month charts
+	s.WeekdayRatios = make([]float32, len(s.Weekdays))
+	for i := 0; i < len(s.Weekdays); i++ {
+		s.WeekdayRatios[i] = ratio(s.Weekdays[i], dated)
+	}
+
+	s.MonthRatios = make([]float32, len(s.Months))
+	for i := 0; i < len(s.Months); i++ {
+		s.MonthRatios[i] = ratio(s.Months[i], dated)
+	}
+
+	for i, v := range s.Weekdays {
+		if v > 0 {
+			s.WeekdayMeanRatings[i] /= float32(v)
+		}
+	}
+
+	if s.WeekdayCount > 0 {
+		s.WeekdayMean /= float32(s.WeekdayCount)
+	}
+	if s.WeekendCount > 0 {
+		s.WeekendMean /= float32(s.WeekendCount)
+	}
+
+	s.RatingRatios = make([]float32, len(s.Ratings))
+	for i := 0; i < len(s.Ratings); i++ {
+		s.RatingRatios[i] = ratio(int(s.Ratings[i]), count)
+	}
+
+	s.countCompleted(menu, covered)
+
+	s.Count = count
+	if count > 0 {
+		s.Mean = total / float32(count)
+	}
+
+	if priced > 0 {
+		s.AvgPrice = s.TotalSpent / float32(priced)
+	}
+
+	s.Median = median(values)
+	i, ties := mode(s.Ratings)
+	s.Mode = bucketValue(i, bucket)
+	for _, i := range ties {
+		s.ModeTies = append(s.ModeTies, bucketValue(i, bucket))
+	}
+
+	s.FormattedLongest = fmt.Sprintf("%.f days", s.Longest.Hours()/24)
+	s.FormattedShortest = "n/a"
+	if hasShortest {
+		s.FormattedShortest = fmt.Sprintf("%.f days", s.Shortest.Hours()/24)
+	}
+
+	s.FormattedAvgGap = "n/a"
+	if dated > 1 {
+		s.AvgGap = s.LastDate.Sub(s.FirstDate) / time.Duration(dated-1)
+		s.FormattedAvgGap = fmt.Sprintf("%.1f days", s.AvgGap.Hours()/24)
+	}
+
+	s.FormattedTotal = fmt.Sprintf("%.f days", s.LastDate.Sub(s.FirstDate).Hours()/24)
+
+	s.PeakWindowStart, s.PeakWindowCount = peakWindow(s.Dated, peakWindowDays)
+	if s.PeakWindowCount > 0 {
+		end := s.PeakWindowStart.AddDate(0, 0, peakWindowDays-1)
+		s.FormattedPeakWindow = fmt.Sprintf("%v–%v", s.PeakWindowStart.Format("Jan 2"), end.Format("Jan 2"))
+	}
+
+	s.Clustered = clusteredDates(s.Dated, clusterSize)
+	s.Slope, s.Trend = trend(s.Dated, s.Max)
+	s.CategoryNames, s.CategoryMeans = categoryMeans(ratings, menu)
+	for i, name := range s.CategoryNames {
+		if i == 0 || s.CategoryMeans[name] > s.CategoryMeans[s.TopCategory] {
+			s.TopCategory = name
+		}
+	}
+	if s.Trend != "" {
+		s.FormattedSlope = fmt.Sprintf("%+.2f points per 30 days", s.Slope*30)
+	}
+
+	return s
+}
+
+// peakWindowDays is the length of the window for the busiest stretch
+const peakWindowDays = 28
+
+// peakWindow finds the window of days, starting on a visit, with the most
+// visits. Ratings must be dated and sorted by date. The earliest window wins
+// ties.
+func peakWindow(dated []Rating, days int) (time.Time, int) {
+	var start time.Time
+	best := 0
+
+	j := 0
+	for i := range dated {
+		end := dated[i].Date.AddDate(0, 0, days)
+		for j < len(dated) && dated[j].Date.Before(end) {
+			j += 1
+		}
+
+		if j-i > best {
+			start = dated[i].Date
+			best = j - i
+		}
+	}
+
+	return start, best
+}
+
+// flatTrend is the largest change over the whole challenge, as a fraction of
+// the max rating, that is still considered flat
+const flatTrend = 0.05
+
+// trend fits a line to the ratings by days since the first visit, returning
+// the slope in points per day and whether that is "up", "down", or "flat".
+// Ratings must be dated and sorted by date. The trend is empty if there are
+// fewer than three ratings or they are all on the same day.
+func trend(dated []Rating, max float32) (float32, string) {
+	if len(dated) < 3 {
+		return 0, ""
+	}
+
+	first := dated[0].Date
+	xs := make([]float64, len(dated))
+	var sx, sy float64
+	for i, rating := range dated {
+		xs[i] = rating.Date.Sub(first).Hours() / 24
+		sx += xs[i]
+		sy += float64(rating.Value)
+	}
+	n := float64(len(dated))
+	mx, my := sx/n, sy/n
+
+	var cov, vx float64
+	for i, rating := range dated {
+		dx := xs[i] - mx
+		cov += dx * (float64(rating.Value) - my)
+		vx += dx * dx
+	}
+
+	if vx == 0 {
+		return 0, ""
+	}
+
+	slope := cov / vx
+
+	// compare the change over the whole span to the rating scale
+	change := slope * xs[len(xs)-1]
+	switch {
+	case change > flatTrend*float64(max):
+		return float32(slope), "up"
+	case change < -flatTrend*float64(max):
+		return float32(slope), "down"
+	default:
+		return float32(slope), "flat"
+	}
+}
+
+// mostRepeated returns the item with the most repeat visits, given the item
+// number of each repeat, along with its number of repeats. The earliest item
+// on the menu wins ties. Repeats of unknown items are ignored.
+func mostRepeated(repeats []int, menu []MenuItem) (*ItemRef, int) {
+	counts := map[int]int{}
+	for _, v := range repeats {
+		counts[v] += 1
+	}
+
+	var res *ItemRef
+	best := 0
+	for _, item := range menu {
+		if n := counts[item.Number]; n > best {
+			res = &ItemRef{item.Number, item.Name}
+			best = n
+		}
+	}
+
+	return res, best
+}
+
+// clusterSize is the most ratings on one date before it looks back-filled
+const clusterSize = 3
+
+// clusteredDates returns the dates with more than n ratings. Ratings must be
+// dated and sorted by date.
+func clusteredDates(dated []Rating, n int) []DateCount {
+	counts := map[time.Time]int{}
+	var dates []time.Time
+
+	for _, rating := range dated {
+		if counts[rating.Date] == 0 {
+			dates = append(dates, rating.Date)
+		}
+		counts[rating.Date] += 1
+	}
+
+	var res []DateCount
+	for _, date := range dates {
+		if counts[date] > n {
+			res = append(res, DateCount{date, counts[date]})
+		}
+	}
+
+	return res
+}
+
+// GroupStats computes the rating distribution across everyone's ratings, after
+// normalizing them to Scale so that different scales can be combined. Only the
+// rating fields are set, there is no meaningful date or menu progress for the
+// group.
+func GroupStats(ratings []Rating, bucket float32) Stats {
+	if bucket <= 0 || math.IsNaN(float64(bucket)) || math.IsInf(float64(bucket), 0) {
+		bucket = 1
+	}
+
+	s := Stats{Bucket: bucket, Max: Scale}
+
+	var total float32
+	var values []float32
+
+	if len(ratings) > 0 {
+		s.Ratings = make([]float32, bucketOf(s.Max, bucket)+1)
+	}
+
+	for _, rating := range ratings {
+		v := rating.Normalized()
+
+		s.Ratings[bucketOf(v, bucket)] += 1
+		total += v
+		values = append(values, v)
+	}
+
+	s.Count = len(ratings)
+	if s.Count > 0 {
+		s.Mean = total / float32(s.Count)
+	}
+
+	s.RatingRatios = make([]float32, len(s.Ratings))
+	for i := range s.Ratings {
+		s.RatingRatios[i] = ratio(int(s.Ratings[i]), s.Count)
+	}
+
+	s.Median = median(values)
+	i, ties := mode(s.Ratings)
+	s.Mode = bucketValue(i, bucket)
+	for _, i := range ties {
+		s.ModeTies = append(s.ModeTies, bucketValue(i, bucket))
+	}
+
+	return s
+}
+
+// countCompleted sets the missing and completed items from the menu items that
+// have been rated
+func (s *Stats) countCompleted(menu []MenuItem, covered map[int]bool) {
+	for _, item := range menu {
+		if !covered[item.Number] {
+			s.Missing = append(s.Missing, ItemRef{item.Number, item.Name})
+		} else {
+			s.Completed += 1
+		}
+	}
+
+	s.Total = len(menu)
+	s.Percent = ratio(s.Completed, s.Total)
+}
+
+// challengeLength is how long the challenge is supposed to take
+const challengeLength = 365 * 24 * time.Hour
+
+// Validate looks for suspicious patterns in the ratings that may be mistakes in
+// the ratings file, returning a description of each
+func (s Stats) Validate() []string {
+	var res []string
+
+	if s.Count > 1 {
+		for i, v := range s.Ratings {
+			if int(v) == s.Count {
+				res = append(res, fmt.Sprintf("all %v ratings are %v", s.Count, bucketValue(i, s.Bucket)))
+			}
+		}
+	}
+
+	if n := len(s.Ratings); s.Count > 1 && n > 2 && s.Ratings[0] > 0 && s.Ratings[n-1] > 0 {
+		if int(s.Ratings[0]+s.Ratings[n-1]) == s.Count {
+			res = append(res, fmt.Sprintf("all ratings are either 0 or %v", s.Max))
+		}
+	}
+
+	if s.OutOfOrder > 0 {
+		res = append(res, fmt.Sprintf("%v ratings are dated before the rating above them", s.OutOfOrder))
+	}
+
+	for _, v := range s.Clustered {
+		res = append(res, fmt.Sprintf("%v ratings on %v, possibly filled in later", v.Count, v.Date.Format("Mon Jan 2 2006")))
+	}
+
+	if s.Longest > challengeLength {
+		res = append(res, fmt.Sprintf("longest gap of %v is longer than the whole challenge", s.FormattedLongest))
+	}
+
+	return res
+}
+
+// categories groups the menu items by category, in the order that each
+// category first appears
+func categories(menu []MenuItem) []Category {
+	var res []Category
+	index := map[string]int{}
+
+	for _, item := range menu {
+		i, ok := index[item.Category]
+		if !ok {
+			i = len(res)
+			index[item.Category] = i
+			res = append(res, Category{Name: item.Category})
+		}
+
+		res[i].Items = append(res[i].Items, item)
+	}
+
+	return res
+}
+
+// uncategorized is the label for items without a category when others have one
+const uncategorized = "Other"
+
+// categoryMeans computes the mean rating in each category, returning the names
+// of the rated categories in menu order. Both are empty if the menu has no
+// categories.
+func categoryMeans(ratings []Rating, menu []MenuItem) ([]string, map[string]float32) {
+	category := map[int]string{}
+	hasCategory := false
+	for _, item := range menu {
+		if _, ok := category[item.Number]; !ok {
+			category[item.Number] = item.Category
+		}
+		if item.Category != "" {
+			hasCategory = true
+		}
+	}
+
+	if !hasCategory {
+		return nil, nil
+	}
+
+	counts := map[string]int{}
+	means := map[string]float32{}
+	for _, rating := range ratings {
+		c, ok := category[rating.Number]
+		if !ok {
+			continue
+		}
+		if c == "" {
+			c = uncategorized
+		}
+
+		counts[c] += 1
+		means[c] += rating.Value
+	}
+
+	var names []string
+	seen := map[string]bool{}
+	for _, c := range categories(menu) {
+		name := c.Name
+		if name == "" {
+			name = uncategorized
+		}
+
+		if counts[name] > 0 && !seen[name] {
+			names = append(names, name)
+			seen[name] = true
+		}
+	}
+
+	for name := range means {
+		means[name] /= float32(counts[name])
+	}
+
+	return names, means
+}
+
+// groupByYear splits dated ratings, which must be sorted by date, by calendar
+// year. Undated ratings are dropped.
+func groupByYear(ratings []Rating) [][]Rating {
+	var res [][]Rating
+
+	for _, rating := range ratings {
+		if rating.Date.IsZero() {
+			continue
+		}
+
+		if n := len(res); n == 0 || res[n-1][0].Date.Year() != rating.Date.Year() {
+			res = append(res, nil)
+		}
+
+		res[len(res)-1] = append(res[len(res)-1], rating)
+	}
+
+	return res
+}
+
+// computeItemStats for each menu item that has ratings
+func computeItemStats(menu []MenuItem) []ItemStats {
+	var res []ItemStats
+
+	for _, item := range menu {
+		if len(item.Ratings) == 0 {
+			continue
+		}
+
+		s := ItemStats{
+			MenuItem:   item,
+			RaterCount: len(item.Ratings),
+		}
+
+		for _, rating := range item.Ratings {
+			s.Mean += rating.Normalized()
+		}
+		s.Mean /= float32(s.RaterCount)
+
+		for _, rating := range item.Ratings {
+			d := rating.Normalized() - s.Mean
+			s.Variance += d * d
+		}
+		s.Variance /= float32(s.RaterCount)
+
+		priced := 0
+		for _, rating := range item.Ratings {
+			if rating.HasPrice {
+				s.Price += rating.Price
+				priced += 1
+			}
+		}
+		if priced > 0 && s.Price > 0 {
+			s.Price /= float32(priced)
+			s.BangForBuck = s.Mean / s.Price
+			s.HasPrice = true
+		}
+
+		res = append(res, s)
+	}
+
+	return res
+}
+
+// rankItems sorts items by mean, highest first
+func rankItems(items []ItemStats) []ItemStats {
+	res := append([]ItemStats(nil), items...)
+
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Mean > res[j].Mean
+	})
+
+	return res
+}
+
+// verdict picks the highest and lowest rated items by mean, breaking ties in
+// favor of items with more ratings. Returns nil if there are no items.
+func verdict(items []ItemStats) (*ItemStats, *ItemStats) {
+	var best, worst *ItemStats
+
+	for i := range items {
+		item := &items[i]
+
+		if best == nil || item.Mean > best.Mean ||
+			(item.Mean == best.Mean && item.RaterCount > best.RaterCount) {
+			best = item
+		}
+
+		if worst == nil || item.Mean < worst.Mean ||
+			(item.Mean == worst.Mean && item.RaterCount > worst.RaterCount) {
+			worst = item
+		}
+	}
+
+	return best, worst
+}
+
+// pearson computes the correlation between xs and ys. Returns false if the
+// correlation is undefined.
+func pearson(xs, ys []float32) (float32, bool) {
+	n := float64(len(xs))
+
+	var sx, sy float64
+	for i := range xs {
+		sx += float64(xs[i])
+		sy += float64(ys[i])
+	}
+	mx, my := sx/n, sy/n
+
+	var cov, vx, vy float64
+	for i := range xs {
+		dx, dy := float64(xs[i])-mx, float64(ys[i])-my
+		cov += dx * dy
+		vx += dx * dx
+		vy += dy * dy
+	}
+
+	if vx == 0 || vy == 0 {
+		return 0, false
+	}
+
+	return float32(cov / math.Sqrt(vx*vy)), true
+}
+
+// correlations computes the pearson correlation for every pair of people over
+// the items they both rated. Pairs with fewer than three items in common are
+// omitted.
+func correlations(menu []MenuItem, people []string) map[string]map[string]float32 {
+	res := map[string]map[string]float32{}
+
+	for _, a := range people {
+		res[a] = map[string]float32{}
+
+		for _, b := range people {
+			if a == b {
+				continue
+			}
+
+			var xs, ys []float32
+			for _, item := range menu {
+				ra, ok := item.Ratings[a]
+				if !ok {
+					continue
+				}
+				rb, ok := item.Ratings[b]
+				if !ok {
+					continue
+				}
+
+				xs = append(xs, ra.Value)
+				ys = append(ys, rb.Value)
+			}
+
+			if len(xs) < 3 {
+				continue
+			}
+
+			if v, ok := pearson(xs, ys); ok {
+				res[a][b] = v
+			}
+		}
+	}
+
+	return res
+}
+
+// valueItems sorts items with prices by bang for buck, highest first. Also
+// returns the number of items that were excluded for not having a price.
+func valueItems(items []ItemStats) ([]ItemStats, int) {
+	var res []ItemStats
+	for _, item := range items {
+		if item.HasPrice {
+			res = append(res, item)
+		}
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].BangForBuck > res[j].BangForBuck
+	})
+
+	return res, len(items) - len(res)
+}
+
+// finishers splits reviewers into those who rated every item on the menu,
+// ordered by when they finished, and everyone else, ordered by how far along
+// they are.
+func finishers(reviewers []PersonStats) (done, rest []Finisher) {
+	for _, p := range reviewers {
+		f := Finisher{Name: p.Name, Percent: p.Stats.Percent}
+
+		if p.Stats.Total > 0 && p.Stats.Completed == p.Stats.Total {
+			f.Date = p.Stats.LastDate
+			if !f.Date.IsZero() {
+				f.FormattedDate = f.Date.Format("Mon Jan 2 2006")
+			}
+			done = append(done, f)
+		} else {
+			rest = append(rest, f)
+		}
+	}
+
+	// finishers without dates go last
+	sort.SliceStable(done, func(i, j int) bool {
+		a, b := done[i].Date, done[j].Date
+		if a.IsZero() || b.IsZero() {
+			return !a.IsZero() && b.IsZero()
+		}
+
+		return a.Before(b)
+	})
+	sort.SliceStable(rest, func(i, j int) bool {
+		return rest[i].Percent > rest[j].Percent
+	})
+
+	return done, rest
+}
+
+// adventurers returns the people who rated the most and fewest items, along
+// with how many they rated. Skipped items count towards completion but not
+// here. Ties are listed in name order. Both are nil if everyone rated the
+// same number of items.
+func adventurers(reviewers []PersonStats) (most []string, mostCount int, fewest []string, fewestCount int) {
+	if len(reviewers) == 0 {
+		return nil, 0, nil, 0
+	}
+
+	rated := func(p PersonStats) int {
+		return p.Stats.Completed - p.Stats.Skipped
+	}
+
+	mostCount, fewestCount = rated(reviewers[0]), rated(reviewers[0])
+	for _, p := range reviewers[1:] {
+		if rated(p) > mostCount {
+			mostCount = rated(p)
+		}
+		if rated(p) < fewestCount {
+			fewestCount = rated(p)
+		}
+	}
+
+	if mostCount == fewestCount {
+		return nil, 0, nil, 0
+	}
+
+	for _, p := range reviewers {
+		switch rated(p) {
+		case mostCount:
+			most = append(most, p.Name)
+		case fewestCount:
+			fewest = append(fewest, p.Name)
+		}
+	}
+
+	return most, mostCount, fewest, fewestCount
+}
+
+// controversialItems sorts items rated by at least two people by variance,
+// highest first
+func controversialItems(items []ItemStats) []ItemStats {
+	var res []ItemStats
+	for _, item := range items {
+		if item.RaterCount >= 2 {
+			res = append(res, item)
+		}
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Variance > res[j].Variance
+	})
+
+	return res
+}
@@ -0,0 +1,143 @@
+package yyl
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testMenu returns a menu with items numbered 1 to n
+func testMenu(n int) []MenuItem {
+	var menu []MenuItem
+	for i := 1; i <= n; i++ {
+		menu = append(menu, MenuItem{
+			Number:  i,
+			Name:    string(rune('A' + i - 1)),
+			Ratings: map[string]Rating{},
+		})
+	}
+
+	return menu
+}
+
+// day returns the date for the year, month, and day
+func day(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestTopItemMixedMax(t *testing.T) {
+	ratings := []Rating{
+		{Number: 1, Date: day(2015, time.January, 6), Value: 5, Max: 5},
+		{Number: 2, Date: day(2015, time.January, 7), Value: 8, Max: 10},
+		{Number: 3, Date: day(2015, time.January, 8), Value: 4, Max: 10},
+		{Number: 4, Date: day(2015, time.January, 9), Value: 3, Max: 5},
+	}
+
+	s := ComputeStats("bob", ratings, testMenu(4), false, 1)
+
+	if s.TopItem.Number != 1 || s.TopValue != 5 || s.TopMax != 5 {
+		t.Errorf("want top item 1 at 5/5, got %v at %v/%v", s.TopItem.Number, s.TopValue, s.TopMax)
+	}
+	if s.BottomItem.Number != 3 || s.BottomValue != 4 || s.BottomMax != 10 {
+		t.Errorf("want bottom item 3 at 4/10, got %v at %v/%v", s.BottomItem.Number, s.BottomValue, s.BottomMax)
+	}
+}
+
+func TestComputeStatsMixedMax(t *testing.T) {
+	fname := writeFile(t, "bob.csv", "number,date,rating,max\n1,20150106,4,5\n2,20150107,9,10\n3,20150108,5,5\n")
+
+	ratings, err := ReadRatings(fname, testOpts, DateLayouts, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := ComputeStats("bob", ratings, testMenu(3), false, 1)
+
+	if s.Max != 10 {
+		t.Errorf("want max 10, got %v", s.Max)
+	}
+	if len(s.Ratings) != 11 {
+		t.Errorf("want histogram sized for /10, got %v buckets", len(s.Ratings))
+	}
+	if s.Count != 3 {
+		t.Errorf("want 3 ratings, got %v", s.Count)
+	}
+}
+
+func TestMaxPerWeekSingleWeek(t *testing.T) {
+	// Monday to Friday of the same iso week
+	var ratings []Rating
+	for i := 0; i < 5; i++ {
+		ratings = append(ratings, Rating{Number: i + 1, Date: day(2015, time.January, 5+i), Value: 5, Max: 10})
+	}
+
+	s := ComputeStats("bob", ratings, testMenu(5), false, 1)
+
+	if s.MaxPerWeek != 5 {
+		t.Errorf("want 5 in the only week, got %v", s.MaxPerWeek)
+	}
+}
+
+func TestMaxPerWeekYearBoundary(t *testing.T) {
+	for _, c := range []struct {
+		name  string
+		dates []time.Time
+		want  int
+	}{
+		{
+			// both are in week 2, a year apart
+			name:  "same week number",
+			dates: []time.Time{day(2015, time.January, 6), day(2016, time.January, 12)},
+			want:  1,
+		},
+		{
+			// the iso year of Dec 29 2014 is 2015
+			name:  "same iso week",
+			dates: []time.Time{day(2014, time.December, 29), day(2015, time.January, 2)},
+			want:  2,
+		},
+	} {
+		var ratings []Rating
+		for i, date := range c.dates {
+			ratings = append(ratings, Rating{Number: i + 1, Date: date, Value: 5, Max: 10})
+		}
+
+		s := ComputeStats("bob", ratings, testMenu(len(ratings)), false, 1)
+
+		if s.MaxPerWeek != c.want {
+			t.Errorf("%v: want %v per week, got %v", c.name, c.want, s.MaxPerWeek)
+		}
+	}
+}
+
+func TestComputeStatsBucket(t *testing.T) {
+	ratings := []Rating{
+		{Number: 1, Date: day(2015, time.January, 6), Value: 0.9, Max: 3},
+		{Number: 2, Date: day(2015, time.January, 7), Value: 2.7, Max: 3},
+		{Number: 3, Date: day(2015, time.January, 8), Value: 1.8, Max: 3},
+	}
+
+	// used to panic sizing the histogram
+	for _, bucket := range []float64{math.NaN(), math.Inf(1), math.Inf(-1), 0} {
+		s := ComputeStats("bob", ratings, testMenu(3), false, float32(bucket))
+		if s.Bucket != 1 {
+			t.Errorf("bucket %v: want the default of 1, got %v", bucket, s.Bucket)
+		}
+
+		if g := GroupStats(ratings, float32(bucket)); g.Bucket != 1 {
+			t.Errorf("bucket %v: want the default group bucket of 1, got %v", bucket, g.Bucket)
+		}
+	}
+
+	s := ComputeStats("bob", ratings, testMenu(3), false, 0.3)
+
+	if s.Mode != 0.9 || len(s.ModeTies) != 2 || s.ModeTies[0] != 1.8 || s.ModeTies[1] != 2.7 {
+		t.Errorf("want mode 0.9 tied with 1.8 and 2.7, got %v tied with %v", s.Mode, s.ModeTies)
+	}
+
+	want := "0 0.3 0.6 0.9 1.2 1.5 1.8 2.1 2.4 2.7 3"
+	if got := strings.Join(s.RatingLabels(), " "); got != want {
+		t.Errorf("want labels %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,627 @@
+// Package yyl reads the menu and ratings for a YYL challenge and computes the
+// stats used to render the report.
+package yyl
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Scale is the common scale that ratings are normalized to before comparing or
+// combining ratings from different people
+const Scale = 10
+
+type Rating struct {
+	Number int
+	Date   time.Time
+	Value  float32
+	Max    float32
+	Note   string
+
+	// Price is only valid if HasPrice is set
+	Price    float32
+	HasPrice bool
+
+	FormattedDate string
+	// RelativeDate is the time since the previous visit, e.g. "3 days later"
+	RelativeDate string
+	// Label is the display label for Value from the scale labels, if any
+	Label string
+
+	// Skipped is set if the item was deliberately not eaten, such as due to
+	// an allergy. It counts towards completion but has no value.
+	Skipped bool
+}
+
+// Normalized returns the value scaled from 0 to Max onto 0 to Scale
+func (r Rating) Normalized() float32 {
+	if r.Max == 0 {
+		return 0
+	}
+
+	return r.Value / r.Max * Scale
+}
+
+type MenuItem struct {
+	Number int
+	Name   string
+	// Category is the optional section of the menu, such as "Appetizers"
+	Category string
+	// Image is the path to the item's image, if HasImage is set. Embed is set
+	// if the image should be inlined as a data URI, see Src.
+	Image    template.URL
+	HasImage bool
+	Embed    bool
+
+	Ratings map[string]Rating
+	// Skipped are the skipped ratings, by person, which are not in Ratings
+	Skipped map[string]Rating
+}
+
+// Category is a section of the menu with its items in menu order
+type Category struct {
+	Name  string
+	Items []MenuItem
+}
+
+// ScaleLabels are display labels for rating values, keyed by max and then by
+// value. Labels with a zero max apply to any max.
+type ScaleLabels map[float32]map[float32]string
+
+// Label returns the label for value out of max, if there is one
+func (l ScaleLabels) Label(value, max float32) (string, bool) {
+	if v, ok := l[max][value]; ok {
+		return v, true
+	}
+
+	v, ok := l[0][value]
+	return v, ok
+}
+
+// NamedRating is a rating along with the name of the person who made it
+type NamedRating struct {
+	Name   string
+	Rating Rating
+}
+
+// SortedRatings returns the ratings for the item, sorted by name
+func (m MenuItem) SortedRatings() []NamedRating {
+	var res []NamedRating
+	for who, rating := range m.Ratings {
+		res = append(res, NamedRating{who, rating})
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+
+	return res
+}
+
+// ItemRef refers to a menu item without its ratings
+type ItemRef struct {
+	Number int
+	Name   string
+}
+
+type Stats struct {
+	Count         int
+	HasDate       bool
+	MaxPerWeek    int
+	Longest       time.Duration
+	LongestAfter  string
+	Shortest      time.Duration
+	ShortestAfter string
+	AvgGap        time.Duration
+	FirstDate     time.Time
+	LastDate      time.Time
+
+	// Weekdays start on WeekStart, Sunday unless rotated
+	WeekStart     time.Weekday `json:"-"`
+	Weekdays      []int
+	WeekdayRatios []float32
+	// WeekdayMeanRatings is zero for weekdays without any visits
+	WeekdayMeanRatings []float32
+	// WeekdayCount and WeekendCount are the number of dated visits during
+	// the week and on the weekend, along with the mean rating of each
+	WeekdayCount int
+	WeekendCount int
+	WeekdayMean  float32
+	WeekendMean  float32
+	// GapBuckets counts the gaps between visits in each of GapLabels
+	GapBuckets []int
+
+	Months       [12]int
+	MonthRatios  []float32
+	Ratings      []float32
+	RatingRatios []float32
+	Mean         float32
+	Median       float32
+	Mode         float32
+	ModeTies     []float32
+	// Bucket is the width of each bucket in Ratings
+	Bucket float32
+	Max    float32
+
+	// Missing are the menu items that have not been rated
+	Missing []ItemRef
+	// TopItem and BottomItem are the highest and lowest rated items on the
+	// menu, by normalized rating, the earliest if there are ties. The value
+	// and max are the item's own rating. Only valid if Completed is set.
+	TopItem     ItemRef
+	TopValue    float32
+	TopMax      float32
+	BottomItem  ItemRef
+	BottomValue float32
+	BottomMax   float32
+
+	// Completed is the number of distinct menu items rated out of Total,
+	// Percent is zero if the menu is empty
+	Completed int
+	Total     int
+	Percent   float32
+
+	// TotalSpent and AvgPrice are computed over ratings with prices, if
+	// HasPrice is set
+	HasPrice   bool
+	TotalSpent float32
+	AvgPrice   float32
+
+	// Dated are the ratings that have dates, sorted by date
+	Dated []Rating `json:"-"`
+	// Skipped is the number of items skipped, included in Completed but not
+	// in any of the rating stats
+	Skipped int
+	// OutOfOrder is the number of ratings in the file dated before the
+	// rating above them
+	OutOfOrder int
+	// Clustered are the dates with suspiciously many ratings
+	Clustered []DateCount
+
+	// Repeats is the number of revisits to items already rated, if repeats
+	// are allowed. MostRepeated is the item with the most repeats, nil if
+	// none, and MostRepeatedVisits is its number of repeats.
+	Repeats            int
+	MostRepeated       *ItemRef
+	MostRepeatedVisits int
+
+	// ScaleLabels are the display labels for rating values, if any
+	ScaleLabels ScaleLabels `json:"-"`
+
+	// Slope is the change in rating per day over the challenge, Trend is
+	// whether that is up, down, or flat. Trend is empty if there are too few
+	// dated ratings.
+	Slope float32
+	Trend string
+
+	// CategoryMeans is the mean rating in each menu category, CategoryNames
+	// are the rated categories in menu order and TopCategory is the one with
+	// the highest mean. All are empty if the menu has no categories.
+	CategoryMeans map[string]float32
+	CategoryNames []string `json:"-"`
+	TopCategory   string
+
+	// PeakWindowStart is the first visit of the 4 week window with the most
+	// visits, PeakWindowCount
+	PeakWindowStart time.Time
+	PeakWindowCount int
+
+	FormattedPeakWindow string
+	FormattedLongest    string
+	FormattedShortest   string
+	FormattedAvgGap     string
+	FormattedTotal      string
+	FormattedSlope      string
+}
+
+// rotateWeekdays reorders the day of week stats so that they begin on start
+// rather than Sunday
+func (s *Stats) rotateWeekdays(start time.Weekday) {
+	n := (int(start) - int(s.WeekStart) + 7) % 7
+	if n == 0 || len(s.Weekdays) != 7 {
+		return
+	}
+	s.WeekStart = start
+
+	s.Weekdays = append(append([]int{}, s.Weekdays[n:]...), s.Weekdays[:n]...)
+	s.WeekdayRatios = append(append([]float32{}, s.WeekdayRatios[n:]...), s.WeekdayRatios[:n]...)
+	s.WeekdayMeanRatings = append(append([]float32{}, s.WeekdayMeanRatings[n:]...), s.WeekdayMeanRatings[:n]...)
+}
+
+// MarshalJSON encodes the durations as a number of days
+func (s Stats) MarshalJSON() ([]byte, error) {
+	type stats Stats
+
+	return json.Marshal(struct {
+		stats
+		Longest  float64
+		Shortest float64
+		AvgGap   float64
+	}{
+		stats:    stats(s),
+		Longest:  s.Longest.Hours() / 24,
+		Shortest: s.Shortest.Hours() / 24,
+		AvgGap:   s.AvgGap.Hours() / 24,
+	})
+}
+
+// LabeledBar is a single bar in a chart, Ratio is the percentage of the total
+// and Count is the underlying number. Label is shown under the bar.
+type LabeledBar struct {
+	Label string
+	Ratio float32
+	Count int
+}
+
+// newBars combines the ratios, counts, and labels for a chart
+func newBars(ratios []float32, counts []int, labels []string) []LabeledBar {
+	var res []LabeledBar
+	for i, v := range ratios {
+		b := LabeledBar{Ratio: v}
+		if i < len(counts) {
+			b.Count = counts[i]
+		}
+		if i < len(labels) {
+			b.Label = labels[i]
+		}
+		res = append(res, b)
+	}
+
+	return res
+}
+
+// WeekdayBars are the bars for the day of week chart
+func (s Stats) WeekdayBars() []LabeledBar {
+	return newBars(s.WeekdayRatios, s.Weekdays, s.WeekdayLabels())
+}
+
+// WeekdayLabels for the day of week charts, starting on WeekStart
+func (s Stats) WeekdayLabels() []string {
+	return WeekdayLabels(s.WeekStart)
+}
+
+// WeekendBars are the bars for the weekday vs weekend chart
+func (s Stats) WeekendBars() []LabeledBar {
+	dated := s.WeekdayCount + s.WeekendCount
+
+	return []LabeledBar{
+		{"Weekday", ratio(s.WeekdayCount, dated), s.WeekdayCount},
+		{"Weekend", ratio(s.WeekendCount, dated), s.WeekendCount},
+	}
+}
+
+// GapLabels are the ranges of days for GapBuckets
+var GapLabels = []string{"0–3", "4–7", "8–14", "15+"}
+
+// gapBucket returns the index in GapBuckets for a gap of days
+func gapBucket(days int) int {
+	switch {
+	case days <= 3:
+		return 0
+	case days <= 7:
+		return 1
+	case days <= 14:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// GapBars are the bars for the gap histogram
+func (s Stats) GapBars() []LabeledBar {
+	total := 0
+	for _, v := range s.GapBuckets {
+		total += v
+	}
+
+	var ratios []float32
+	for _, v := range s.GapBuckets {
+		ratios = append(ratios, ratio(v, total))
+	}
+
+	return newBars(ratios, s.GapBuckets, GapLabels)
+}
+
+// MonthBars are the bars for the month chart
+func (s Stats) MonthBars() []LabeledBar {
+	return newBars(s.MonthRatios, s.Months[:], MonthLabels())
+}
+
+// RatingBars are the bars for the rating histogram
+func (s Stats) RatingBars() []LabeledBar {
+	var counts []int
+	for _, v := range s.Ratings {
+		counts = append(counts, int(v))
+	}
+
+	return newBars(s.RatingRatios, counts, s.RatingLabels())
+}
+
+// RatingLabels are the labels for the rating histogram, the lowest rating in
+// each bucket or its label from ScaleLabels
+func (s Stats) RatingLabels() []string {
+	var res []string
+	for i := range s.Ratings {
+		v := bucketValue(i, s.Bucket)
+		if label, ok := s.ScaleLabels.Label(v, s.Max); ok {
+			res = append(res, label)
+			continue
+		}
+
+		res = append(res, strconv.FormatFloat(float64(v), 'g', -1, 32))
+	}
+
+	return res
+}
+
+// CategoryMeanRatings returns CategoryMeans in the order of CategoryNames
+func (s Stats) CategoryMeanRatings() []float32 {
+	var res []float32
+	for _, name := range s.CategoryNames {
+		res = append(res, s.CategoryMeans[name])
+	}
+
+	return res
+}
+
+// WeekdayLabels for the day of week charts, starting on start
+func WeekdayLabels(start time.Weekday) []string {
+	var res []string
+	for i := 0; i < 7; i++ {
+		res = append(res, ((start + time.Weekday(i)) % 7).String()[:3])
+	}
+
+	return res
+}
+
+// MonthLabels for the month of year charts
+func MonthLabels() []string {
+	var res []string
+	for i := time.January; i <= time.December; i++ {
+		res = append(res, i.String()[:3])
+	}
+
+	return res
+}
+
+// IndexLabels returns labels 0 through n-1 for the rating charts
+func IndexLabels(n int) []string {
+	var res []string
+	for i := 0; i < n; i++ {
+		res = append(res, strconv.Itoa(i))
+	}
+
+	return res
+}
+
+// ItemStats are the aggregate ratings across everyone for a menu item
+type ItemStats struct {
+	MenuItem
+
+	// Mean and Variance are of the ratings normalized to Scale
+	Mean       float32
+	Variance   float32
+	RaterCount int
+
+	// Price is the average of the recorded prices and BangForBuck is the mean
+	// rating divided by the price, only valid if HasPrice is set
+	Price       float32
+	BangForBuck float32
+	HasPrice    bool
+}
+
+// DateCount is the number of ratings on a date
+type DateCount struct {
+	Date  time.Time
+	Count int
+}
+
+// PersonStats is a person's name along with their stats
+type PersonStats struct {
+	Name  string
+	Stats Stats
+}
+
+// Finisher is a person's progress through the menu. Date is when they rated
+// their last item, only set for people who rated everything.
+type Finisher struct {
+	Name          string
+	Percent       float32
+	Date          time.Time
+	FormattedDate string
+}
+
+// YearStats are the stats for a single calendar year
+type YearStats struct {
+	Year  int
+	Stats Stats
+}
+
+// Report is the data passed to the templates, including custom templates set
+// with -template. Templates may use:
+//
+//	.Title, .Intro      header text
+//	.Menu               menu items, each with .Ratings by person and .Src
+//	.Categories         menu items grouped by category
+//	.Stats              per-person Stats, keyed by name
+//	.Reviewers          per-person Stats, sorted by name
+//	.Group              rating distribution across everyone
+//	.Scale              common scale for .Group and the item means
+//	.People             sorted names of everyone in .Stats
+//	.Years              per-year Stats for each person, if ByYear is set
+//	.Top, .Bottom       best and worst items by mean rating
+//	.Controversial      items with the highest variance
+//	.BestValue          items with the highest rating per price
+//	.Unpriced           number of rated items without prices
+//	.Favorite           group favorite, nil if nothing is rated
+//	.LeastFavorite      group least favorite, nil if nothing is rated
+//	.GroupCompleted     items rated across everyone, of .GroupTotal
+//	.Finishers          people who rated everything, by finish date
+//	.MostRepeated       item revisited the most, with .MostRepeatedVisits
+//	.Adventurous        people who rated the most items, with .AdventurousCount
+//	.Cautious           people who rated the fewest items, with .CautiousCount
+//	.Unfinished         everyone else, by percent complete
+//	.Correlation a b    formatted correlation between two people
+//	.Charts, .Theme     chart renderer and colors
+//	.ShowChart name     whether to draw the named chart, see ChartNames
+//	.Mode               initial color scheme
+//	.Collapse           whether menu items start collapsed
+//	.Currency           currency symbol for prices
+//	.FormattedGenerated when the report was generated
+//	.Anonymized         whether names were replaced with aliases
+//	.Version            the yyl version, if .Stamp is set
+//
+// Custom templates may also use {{ template "stats" (statsArgs $ $who $stats) }}
+// to render a person's stats in the same way as the embedded template.
+type Report struct {
+	Title string
+	Intro string
+
+	Menu []MenuItem
+	// Categories are the menu items grouped by category, in order of first
+	// appearance. Uncategorized items are in a category with no name.
+	Categories []Category `json:"-"`
+	Stats      map[string]Stats
+	// Reviewers are the same as Stats, sorted by name
+	Reviewers []PersonStats `json:"-"`
+	// Group is the rating distribution across everyone, normalized to Scale
+	Group Stats
+	// Scale is the common scale for the group stats and item means
+	Scale float32
+	// Years are the per-year stats for each person, if enabled
+	Years map[string][]YearStats
+
+	// Top and Bottom are the best and worst rated items, by mean
+	Top    []ItemStats
+	Bottom []ItemStats
+	// Controversial items have the highest variance in their ratings
+	Controversial []ItemStats
+	// BestValue items have the highest mean rating per price, Unpriced
+	// counts the rated items without prices
+	BestValue []ItemStats
+	Unpriced  int
+
+	// Favorite and LeastFavorite are the group's verdict, nil if nothing has
+	// been rated
+	Favorite      *ItemStats
+	LeastFavorite *ItemStats
+
+	// GroupCompleted is the number of items rated across everyone, out of
+	// GroupTotal, everyone times the number of menu items
+	GroupCompleted int
+	GroupTotal     int
+	GroupPercent   float32
+
+	// Finishers rated every item, ordered by when they finished. Unfinished
+	// are everyone else, ordered by their progress.
+	Finishers  []Finisher
+	Unfinished []Finisher
+
+	// MostRepeated is the item with the most repeats across everyone, nil if
+	// there are none, and MostRepeatedVisits is its number of repeats
+	MostRepeated       *ItemRef
+	MostRepeatedVisits int
+
+	// Adventurous and Cautious are the people who rated the most and fewest
+	// items, ties included. Both are empty if everyone rated the same number.
+	Adventurous      []string
+	AdventurousCount int
+	Cautious         []string
+	CautiousCount    int
+
+	// Correlations between each pair of people's ratings, missing if they
+	// have too few items in common
+	Correlations map[string]map[string]float32
+
+	// Charts is the chart renderer, either css or svg
+	Charts string
+	// ChartSet are the names of the charts to draw, all of them if empty
+	ChartSet []string
+	Theme    Theme
+	// Mode is the initial color scheme: light, dark, or auto
+	Mode string
+	// Collapse is set if menu items should start collapsed
+	Collapse bool
+	// Currency symbol for prices
+	Currency string
+
+	// Anonymized is set if names were replaced with aliases
+	Anonymized bool
+
+	// Generated is when the report was generated
+	Generated          time.Time
+	FormattedGenerated string `json:"-"`
+
+	// Stamp is set if Version and Generated are stamped into the page
+	Stamp   bool   `json:"-"`
+	Version string `json:"-"`
+}
+
+// Theme contains the colors used for the charts
+type Theme struct {
+	Fill  string
+	Track string
+	Text  string
+}
+
+var reColor = regexp.MustCompile(`^(#([0-9a-fA-F]{3,4}|[0-9a-fA-F]{6}|[0-9a-fA-F]{8})|[a-zA-Z]+)$`)
+
+// Validate checks that the colors look like hex or named css colors
+func (t Theme) Validate() error {
+	for _, c := range []string{t.Fill, t.Track, t.Text} {
+		if !reColor.MatchString(c) {
+			return fmt.Errorf("invalid color %q", c)
+		}
+	}
+
+	return nil
+}
+
+// Correlation formats the correlation between a and b for display
+func (r Report) Correlation(a, b string) string {
+	if a == b {
+		return "-"
+	}
+
+	v, ok := r.Correlations[a][b]
+	if !ok {
+		return "n/a"
+	}
+
+	return fmt.Sprintf("%.2f", v)
+}
+
+// ChartNames are the charts that can be turned on and off, in the order they
+// are drawn
+var ChartNames = []string{"calendar", "progress", "weekday", "month", "weekday-rating", "gaps", "weekend", "category", "rating"}
+
+// ShowChart checks whether the named chart should be drawn
+func (r Report) ShowChart(name string) bool {
+	if len(r.ChartSet) == 0 {
+		return true
+	}
+
+	for _, v := range r.ChartSet {
+		if v == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// People returns the names of everyone with stats, sorted
+func (r Report) People() []string {
+	var res []string
+	for who := range r.Stats {
+		res = append(res, who)
+	}
+	sort.Strings(res)
+
+	return res
+}